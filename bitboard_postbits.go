@@ -13,3 +13,21 @@ func (b bitboard) Reverse() bitboard {
 func (b bitboard) Occupied(sq Square) bool {
 	return (bits.RotateLeft64(uint64(b), int(sq)+1) & 1) == 1
 }
+
+// first returns the lowest square whose bitboard position is 1 without
+// allocating.  A1 is the most significant bit so the number of leading
+// zeros is the square.  The bitboard must not be empty.
+func (b bitboard) first() Square {
+	return Square(bits.LeadingZeros64(uint64(b)))
+}
+
+// squares returns the squares whose bitboard positions are 1 in square order.
+func (b bitboard) squares() []Square {
+	sqs := make([]Square, 0, bits.OnesCount64(uint64(b)))
+	for bb := uint64(b); bb != 0; {
+		sq := bits.LeadingZeros64(bb)
+		sqs = append(sqs, Square(sq))
+		bb &^= 1 << uint(63-sq)
+	}
+	return sqs
+}
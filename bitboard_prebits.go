@@ -40,3 +40,24 @@ func (b bitboard) Occupied(sq Square) bool {
 }
 
 //
+
+// first returns the lowest square whose bitboard position is 1 without
+// allocating.  The bitboard must not be empty.
+func (b bitboard) first() Square {
+	sq := 0
+	for !b.Occupied(Square(sq)) {
+		sq++
+	}
+	return Square(sq)
+}
+
+// squares returns the squares whose bitboard positions are 1 in square order.
+func (b bitboard) squares() []Square {
+	sqs := []Square{}
+	for sq := 0; sq < numOfSquaresInBoard; sq++ {
+		if b.Occupied(Square(sq)) {
+			sqs = append(sqs, Square(sq))
+		}
+	}
+	return sqs
+}
@@ -108,17 +108,51 @@ func (b *Board) Transpose() *Board {
 
 // Draw returns visual representation of the board useful for debugging.
 func (b *Board) Draw() string {
-	s := "\n A B C D E F G H\n"
-	for r := 7; r >= 0; r-- {
-		s += Rank(r).String()
-		for f := 0; f < numOfSquaresInRow; f++ {
-			p := b.Piece(NewSquare(File(f), Rank(r)))
-			if p == NoPiece {
-				s += "-"
-			} else {
-				s += p.String()
-			}
-			s += " "
+	return b.DrawFrom(White)
+}
+
+// DrawFrom returns a visual representation of the board like Draw's
+// but from the perspective of the given color.  If c is Black, rank 1
+// is at the top and file h is on the left.
+func (b *Board) DrawFrom(c Color) string {
+	return b.draw(c, func(p Piece) string {
+		if p == NoPiece {
+			return "-"
+		}
+		return p.String()
+	})
+}
+
+// DrawASCII returns a visual representation of the board like Draw's
+// but using FEN letters for pieces and dots for empty squares.  It is
+// useful for terminals that can't display the unicode chess symbols.
+func (b *Board) DrawASCII() string {
+	return b.draw(White, func(p Piece) string {
+		if p == NoPiece {
+			return "."
+		}
+		return p.getFENChar()
+	})
+}
+
+func (b *Board) draw(c Color, pieceStr func(p Piece) string) string {
+	files := []File{FileA, FileB, FileC, FileD, FileE, FileF, FileG, FileH}
+	ranks := []Rank{Rank8, Rank7, Rank6, Rank5, Rank4, Rank3, Rank2, Rank1}
+	if c == Black {
+		for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+			files[i], files[j] = files[j], files[i]
+			ranks[i], ranks[j] = ranks[j], ranks[i]
+		}
+	}
+	s := "\n"
+	for _, f := range files {
+		s += " " + strings.ToUpper(f.String())
+	}
+	s += "\n"
+	for _, r := range ranks {
+		s += r.String()
+		for _, f := range files {
+			s += pieceStr(b.Piece(NewSquare(f, r))) + " "
 		}
 		s += "\n"
 	}
@@ -216,6 +250,10 @@ func (b *Board) UnmarshalBinary(data []byte) error {
 }
 
 func (b *Board) update(m *Move) {
+	if m.HasTag(KingSideCastle) || m.HasTag(QueenSideCastle) {
+		b.castle(m)
+		return
+	}
 	p1 := b.Piece(m.s1)
 	s1BB := bbForSquare(m.s1)
 	s2BB := bbForSquare(m.s2)
@@ -249,19 +287,39 @@ func (b *Board) update(m *Move) {
 			b.bbWhitePawn = ^(bbForSquare(m.s2) >> 8) & b.bbWhitePawn
 		}
 	}
-	// move rook for castle
-	if p1.Color() == White && m.HasTag(KingSideCastle) {
-		b.bbWhiteRook = (b.bbWhiteRook & ^bbForSquare(H1) | bbForSquare(F1))
-	} else if p1.Color() == White && m.HasTag(QueenSideCastle) {
-		b.bbWhiteRook = (b.bbWhiteRook & ^bbForSquare(A1)) | bbForSquare(D1)
-	} else if p1.Color() == Black && m.HasTag(KingSideCastle) {
-		b.bbBlackRook = (b.bbBlackRook & ^bbForSquare(H8) | bbForSquare(F8))
-	} else if p1.Color() == Black && m.HasTag(QueenSideCastle) {
-		b.bbBlackRook = (b.bbBlackRook & ^bbForSquare(A8)) | bbForSquare(D8)
-	}
 	b.calcConvienceBBs(m)
 }
 
+// drop places the piece on the empty square for a Crazyhouse drop.
+func (b *Board) drop(p Piece, sq Square) {
+	b.setBBForPiece(p, b.bbForPiece(p)|bbForSquare(sq))
+	// the kings don't move since kings can't be dropped
+	b.calcConvienceBBs(&Move{s1: sq, s2: sq})
+}
+
+// castle moves the king and rook for a castling move.  Castles are encoded
+// as the king moving to its destination in standard chess and as the king
+// capturing its own rook in Chess960 where the destination can be occupied.
+func (b *Board) castle(m *Move) {
+	king := b.Piece(m.s1)
+	rook := NewPiece(Rook, king.Color())
+	r := m.s1.Rank()
+	kingTo, rookFrom, rookTo := NewSquare(FileG, r), NewSquare(FileH, r), NewSquare(FileF, r)
+	if m.HasTag(QueenSideCastle) {
+		kingTo, rookFrom, rookTo = NewSquare(FileC, r), NewSquare(FileA, r), NewSquare(FileD, r)
+	}
+	if b.Piece(m.s2) == rook {
+		rookFrom = m.s2
+	}
+	// remove both pieces before placing them since either can land
+	// on the square the other started from
+	kingBB := b.bbForPiece(king) & ^bbForSquare(m.s1)
+	rookBB := b.bbForPiece(rook) & ^bbForSquare(rookFrom)
+	b.setBBForPiece(king, kingBB|bbForSquare(kingTo))
+	b.setBBForPiece(rook, rookBB|bbForSquare(rookTo))
+	b.calcConvienceBBs(&Move{s1: m.s1, s2: kingTo})
+}
+
 func (b *Board) calcConvienceBBs(m *Move) {
 	whiteSqs := b.bbWhiteKing | b.bbWhiteQueen | b.bbWhiteRook | b.bbWhiteBishop | b.bbWhiteKnight | b.bbWhitePawn
 	blackSqs := b.bbBlackKing | b.bbBlackQueen | b.bbBlackRook | b.bbBlackBishop | b.bbBlackKnight | b.bbBlackPawn
@@ -288,6 +346,16 @@ func (b *Board) calcConvienceBBs(m *Move) {
 	}
 }
 
+func (b *Board) kingSquare(c Color) Square {
+	switch c {
+	case White:
+		return b.whiteKingSq
+	case Black:
+		return b.blackKingSq
+	}
+	return NoSquare
+}
+
 func (b *Board) copy() *Board {
 	return &Board{
 		whiteSqs:      b.whiteSqs,
@@ -342,6 +410,7 @@ func (b *Board) hasSufficientMaterial() bool {
 		return false
 	}
 	// king and bishop(s) versus king and bishop(s) with the bishops on the same colour.
+	// any other combination, including knights on both sides, can still checkmate.
 	if count[Knight] == 0 {
 		whiteCount := 0
 		blackCount := 0
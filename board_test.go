@@ -88,3 +88,55 @@ func TestBoardTranspose(t *testing.T) {
 		t.Fatalf("expected board string %s but got %s", b, board.String())
 	}
 }
+
+func TestBoardDraw(t *testing.T) {
+	board := NewGame().Position().Board()
+	expected := "\n A B C D E F G H\n" +
+		"8♜ ♞ ♝ ♛ ♚ ♝ ♞ ♜ \n" +
+		"7♟ ♟ ♟ ♟ ♟ ♟ ♟ ♟ \n" +
+		"6- - - - - - - - \n" +
+		"5- - - - - - - - \n" +
+		"4- - - - - - - - \n" +
+		"3- - - - - - - - \n" +
+		"2♙ ♙ ♙ ♙ ♙ ♙ ♙ ♙ \n" +
+		"1♖ ♘ ♗ ♕ ♔ ♗ ♘ ♖ \n"
+	if board.Draw() != expected {
+		t.Fatalf("expected board drawing %s but got %s", expected, board.Draw())
+	}
+}
+
+func TestBoardDrawASCII(t *testing.T) {
+	board := NewGame().Position().Board()
+	expected := "\n A B C D E F G H\n" +
+		"8r n b q k b n r \n" +
+		"7p p p p p p p p \n" +
+		"6. . . . . . . . \n" +
+		"5. . . . . . . . \n" +
+		"4. . . . . . . . \n" +
+		"3. . . . . . . . \n" +
+		"2P P P P P P P P \n" +
+		"1R N B Q K B N R \n"
+	if board.DrawASCII() != expected {
+		t.Fatalf("expected board drawing %s but got %s", expected, board.DrawASCII())
+	}
+}
+
+func TestBoardDrawFromBlack(t *testing.T) {
+	fen, _ := FEN("4k3/8/8/8/8/8/8/R3K3 w - - 0 1")
+	board := NewGame(fen).Position().Board()
+	expected := "\n H G F E D C B A\n" +
+		"1- - - ♔ - - - ♖ \n" +
+		"2- - - - - - - - \n" +
+		"3- - - - - - - - \n" +
+		"4- - - - - - - - \n" +
+		"5- - - - - - - - \n" +
+		"6- - - - - - - - \n" +
+		"7- - - - - - - - \n" +
+		"8- - - ♚ - - - - \n"
+	if board.DrawFrom(Black) != expected {
+		t.Fatalf("expected board drawing %s but got %s", expected, board.DrawFrom(Black))
+	}
+	if board.DrawFrom(White) != board.Draw() {
+		t.Fatalf("expected drawing from white to match Draw")
+	}
+}
@@ -0,0 +1,113 @@
+package chess
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// A WeightedMove is a move in a Book along with its weight.
+type WeightedMove struct {
+	Move   *Move
+	Weight int
+}
+
+// A Book is an opening book of weighted moves keyed by the Zobrist hash
+// of the position they are played from, so moves reached by
+// transpositions or with different move counters are shared.  The zero
+// value is an empty book ready to use.  A Book isn't safe for concurrent
+// use while moves are being added.
+type Book struct {
+	entries map[uint64][]WeightedMove
+}
+
+// bookEntrySize is the number of bytes of each entry in a binary book:
+// the hash, the packed move and the weight.
+const bookEntrySize = 8 + 2 + 4
+
+// Add adds the move to the book for the position with the given weight.
+// Adding a move that is already in the book for the position adds to its
+// weight.  Moves that aren't valid in the position are ignored.
+func (b *Book) Add(pos *Position, m *Move, weight int) {
+	valid, ok := legalMove(pos, m)
+	if !ok {
+		return
+	}
+	if b.entries == nil {
+		b.entries = map[uint64][]WeightedMove{}
+	}
+	h := pos.ZobristHash()
+	for i, wm := range b.entries[h] {
+		if wm.Move.Equal(valid) {
+			b.entries[h][i].Weight += weight
+			return
+		}
+	}
+	mv := Move{s1: valid.s1, s2: valid.s2, promo: valid.promo, drop: valid.drop}
+	b.entries[h] = append(b.entries[h], WeightedMove{Move: &mv, Weight: weight})
+}
+
+// Probe returns the book's moves for the position ordered by weight from
+// highest to lowest, with moves of equal weight in the order they were
+// added.  The moves are validated and tagged for the position so hash
+// collisions can't return invalid moves.  An empty slice is returned if
+// the position isn't in the book.
+func (b *Book) Probe(pos *Position) []WeightedMove {
+	moves := []WeightedMove{}
+	for _, wm := range b.entries[pos.ZobristHash()] {
+		if valid, ok := legalMove(pos, wm.Move); ok {
+			moves = append(moves, WeightedMove{Move: &valid, Weight: wm.Weight})
+		}
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		return moves[i].Weight > moves[j].Weight
+	})
+	return moves
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.  Each
+// move is encoded as 14 bytes: the big endian uint64 position hash, the
+// two bytes of the move as packed by Move.MarshalBinary without the tags
+// byte and the big endian int32 weight.  Entries are sorted by hash so
+// equal books have equal encodings.
+func (b *Book) MarshalBinary() (data []byte, err error) {
+	hashes := make([]uint64, 0, len(b.entries))
+	n := 0
+	for h, moves := range b.entries {
+		hashes = append(hashes, h)
+		n += len(moves)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	data = make([]byte, n*bookEntrySize)
+	i := 0
+	for _, h := range hashes {
+		for _, wm := range b.entries[h] {
+			binary.BigEndian.PutUint64(data[i:], h)
+			binary.BigEndian.PutUint16(data[i+8:], wm.Move.packed())
+			binary.BigEndian.PutUint32(data[i+10:], uint32(int32(wm.Weight)))
+			i += bookEntrySize
+		}
+	}
+	return data, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface and
+// replaces the book's moves with those encoded by MarshalBinary.
+func (b *Book) UnmarshalBinary(data []byte) error {
+	if len(data)%bookEntrySize != 0 {
+		return errors.New("chess: unable to unmarshal book: incorrect data length")
+	}
+	entries := map[uint64][]WeightedMove{}
+	for i := 0; i < len(data); i += bookEntrySize {
+		h := binary.BigEndian.Uint64(data[i:])
+		mv, err := unpackMove(binary.BigEndian.Uint16(data[i+8:]))
+		if err != nil {
+			return err
+		}
+		mv.tags = 0
+		weight := int(int32(binary.BigEndian.Uint32(data[i+10:])))
+		entries[h] = append(entries[h], WeightedMove{Move: &mv, Weight: weight})
+	}
+	b.entries = entries
+	return nil
+}
@@ -0,0 +1,79 @@
+package chess
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBook(t *testing.T) {
+	var book Book
+	start := StartingPosition()
+	if moves := book.Probe(start); len(moves) != 0 {
+		t.Fatalf("expected an empty book but got %v", moves)
+	}
+	book.Add(start, &Move{s1: E2, s2: E4}, 10)
+	book.Add(start, &Move{s1: D2, s2: D4}, 8)
+	book.Add(start, &Move{s1: G1, s2: F3}, 8)
+	book.Add(start, &Move{s1: D2, s2: D4}, 4)
+	// invalid moves are ignored
+	book.Add(start, &Move{s1: E2, s2: E5}, 100)
+
+	e4 := start.Update(&Move{s1: E2, s2: E4})
+	book.Add(e4, &Move{s1: C7, s2: C5}, 5)
+	// the move counters aren't part of the position's key
+	later := unsafeFEN("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 3 9")
+	book.Add(later, &Move{s1: E7, s2: E5}, 7)
+
+	tests := []struct {
+		pos   *Position
+		moves []string
+		wts   []int
+	}{
+		{start, []string{"d2d4", "e2e4", "g1f3"}, []int{12, 10, 8}},
+		{e4, []string{"e7e5", "c7c5"}, []int{7, 5}},
+		{e4.Update(&Move{s1: C7, s2: C5}), []string{}, []int{}},
+	}
+	check := func(b *Book) {
+		for _, test := range tests {
+			probed := b.Probe(test.pos)
+			moves, wts := []string{}, []int{}
+			for _, wm := range probed {
+				moves = append(moves, wm.Move.String())
+				wts = append(wts, wm.Weight)
+			}
+			if !reflect.DeepEqual(moves, test.moves) || !reflect.DeepEqual(wts, test.wts) {
+				t.Fatalf("expected %v with weights %v but got %v with weights %v", test.moves, test.wts, moves, wts)
+			}
+		}
+	}
+	check(&book)
+
+	data, err := book.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 5*bookEntrySize {
+		t.Fatalf("expected %d bytes but got %d", 5*bookEntrySize, len(data))
+	}
+	var loaded Book
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	check(&loaded)
+	again, err := loaded.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, again) {
+		t.Fatal("expected the same encoding after loading the book")
+	}
+	// probed moves are tagged for the position
+	pos := unsafeFEN("4k3/8/8/3p4/4P3/8/8/4K3 w - - 0 1")
+	book.Add(pos, &Move{s1: E4, s2: D5}, 1)
+	if moves := book.Probe(pos); len(moves) != 1 || !moves[0].Move.HasTag(Capture) {
+		t.Fatalf("expected a capture but got %v", moves)
+	}
+	if err := loaded.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Fatal("expected an error for truncated data")
+	}
+}
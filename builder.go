@@ -0,0 +1,110 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A BoardBuilder builds a position piece by piece as an alternative to
+// writing a FEN.  The zero value isn't usable, use NewBoardBuilder.
+// Setters return the builder so calls can be chained.
+type BoardBuilder struct {
+	pieces       map[Square]Piece
+	turn         Color
+	castleRights CastleRights
+	enPassant    Square
+}
+
+// NewBoardBuilder returns a builder for an empty board with white to
+// move, no castle rights and no en passant square.
+func NewBoardBuilder() *BoardBuilder {
+	return &BoardBuilder{
+		pieces:       map[Square]Piece{},
+		turn:         White,
+		castleRights: "-",
+		enPassant:    NoSquare,
+	}
+}
+
+// Put places the piece on the square replacing any piece already there.
+// Putting NoPiece removes the square's piece.
+func (b *BoardBuilder) Put(sq Square, p Piece) *BoardBuilder {
+	if p == NoPiece {
+		return b.Remove(sq)
+	}
+	b.pieces[sq] = p
+	return b
+}
+
+// Remove removes the piece on the square if there is one.
+func (b *BoardBuilder) Remove(sq Square) *BoardBuilder {
+	delete(b.pieces, sq)
+	return b
+}
+
+// SetTurn sets the color to move.
+func (b *BoardBuilder) SetTurn(c Color) *BoardBuilder {
+	b.turn = c
+	return b
+}
+
+// SetCastle sets the castle rights such as "KQkq" or "-" for none.
+func (b *BoardBuilder) SetCastle(cr CastleRights) *BoardBuilder {
+	b.castleRights = cr
+	return b
+}
+
+// SetEnPassant sets the en passant square.  NoSquare clears it.
+func (b *BoardBuilder) SetEnPassant(sq Square) *BoardBuilder {
+	b.enPassant = sq
+	return b
+}
+
+// Build returns the position with a half move clock of 0 and a move
+// count of 1.  An error is returned if the position isn't legal: each
+// side must have one king, no pawns can be on the first or eighth rank,
+// the side not to move can't be in check, the castle rights must match
+// the kings and rooks on their starting squares and the en passant square
+// must follow a double pawn advance.  The errors wrap the matching
+// ErrFEN errors.
+func (b *BoardBuilder) Build() (*Position, error) {
+	if b.turn != White && b.turn != Black {
+		return nil, fmt.Errorf("%w: %s", ErrFENTurn, b.turn)
+	}
+	pieces := map[Square]Piece{}
+	for sq, p := range b.pieces {
+		pieces[sq] = p
+	}
+	board := NewBoard(pieces)
+	pos := &Position{
+		board:           board,
+		turn:            b.turn,
+		castleRights:    "-",
+		enPassantSquare: b.enPassant,
+		moveCount:       1,
+		castleRooks:     [4]Square{NoSquare, NoSquare, NoSquare, NoSquare},
+	}
+	if err := validateFENPosition(pos); err != nil {
+		return nil, err
+	}
+	if (board.bbWhitePawn|board.bbBlackPawn)&(bbRank1|bbRank8) != 0 {
+		return nil, fmt.Errorf("%w: %s", ErrFENPawnRank, board)
+	}
+	if isInCheck(&Position{board: board, turn: b.turn.Other()}) {
+		return nil, fmt.Errorf("chess: %s is in check with %s to move", b.turn.Other().Name(), b.turn.Name())
+	}
+	if _, err := formCastleRights(string(b.castleRights)); err != nil || strings.ContainsAny(strings.ToLower(string(b.castleRights)), fileChars) {
+		return nil, fmt.Errorf("%w: %s", ErrFENCastleRights, b.castleRights)
+	}
+	cp := pos.WithCastleRights(b.castleRights)
+	for _, c := range []Color{White, Black} {
+		for _, side := range []Side{KingSide, QueenSide} {
+			if b.castleRights.CanCastle(c, side) && !cp.castleRights.CanCastle(c, side) {
+				return nil, fmt.Errorf("%w: %s without the king and rook on their starting squares", ErrFENCastleRights, b.castleRights)
+			}
+		}
+	}
+	pos.castleRights = cp.castleRights
+	pos.inCheck = isInCheck(pos)
+	return pos, nil
+}
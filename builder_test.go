@@ -0,0 +1,57 @@
+package chess
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBoardBuilder(t *testing.T) {
+	pos, err := NewBoardBuilder().
+		Put(E1, WhiteKing).Put(H1, WhiteRook).Put(A1, WhiteRook).
+		Put(E8, BlackKing).Put(D5, BlackPawn).Put(E5, WhitePawn).
+		Put(C3, WhiteKnight).Remove(C3).
+		SetTurn(White).SetCastle("KQ").SetEnPassant(D6).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "4k3/8/8/3pP3/8/8/8/R3K2R w KQ d6 0 1"
+	if pos.String() != expected {
+		t.Fatalf("expected position %s but got %s", expected, pos)
+	}
+	if len(pos.ValidMoves()) != len(unsafeFEN(pos.String()).ValidMoves()) {
+		t.Fatalf("expected built position to have the same moves as its fen")
+	}
+	pos, err = NewBoardBuilder().Put(E1, WhiteKing).Put(E8, BlackKing).Put(E2, BlackQueen).SetTurn(White).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pos.inCheck {
+		t.Fatal("expected built position to be in check")
+	}
+}
+
+func TestBoardBuilderInvalid(t *testing.T) {
+	tests := []struct {
+		b   *BoardBuilder
+		err error
+	}{
+		{NewBoardBuilder().Put(E1, WhiteKing), ErrFENKings},
+		{NewBoardBuilder().Put(E1, WhiteKing).Put(E8, BlackKing).Put(D1, WhiteKing), ErrFENKings},
+		{NewBoardBuilder().Put(E1, WhiteKing).Put(E8, BlackKing).Put(A8, WhitePawn), ErrFENPawnRank},
+		{NewBoardBuilder().Put(E1, WhiteKing).Put(E8, BlackKing).SetCastle("K"), ErrFENCastleRights},
+		{NewBoardBuilder().Put(E1, WhiteKing).Put(E8, BlackKing).Put(H1, WhiteRook).SetCastle("H"), ErrFENCastleRights},
+		{NewBoardBuilder().Put(E1, WhiteKing).Put(E8, BlackKing).SetEnPassant(D6), ErrFENEnPassant},
+		{NewBoardBuilder().Put(E1, WhiteKing).Put(E8, BlackKing).SetTurn(NoColor), ErrFENTurn},
+		{NewBoardBuilder().Put(E1, WhiteKing).Put(E8, BlackKing).Put(E2, WhiteQueen).SetTurn(White), nil},
+	}
+	for i, test := range tests {
+		_, err := test.b.Build()
+		if err == nil {
+			t.Fatalf("expected test %d to be invalid", i)
+		}
+		if test.err != nil && !errors.Is(err, test.err) {
+			t.Fatalf("expected test %d error to wrap %v but got %v", i, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// chess960KnightFiles are the placements of the two knights on the five
+// files left after placing the bishops and queen in Scharnagl's numbering.
+var chess960KnightFiles = [10][2]int{
+	{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2},
+	{1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4},
+}
+
+// NewChess960Position returns the Chess960 (Fischer Random) starting
+// position for the given id using the standard numbering from 0 to 959.
+// Position 518 is the standard starting position.  The position castles
+// with the rooks on any files and its FEN uses Shredder-FEN castling rights
+// such as HAha.  Castling moves are encoded as the king moving to the
+// square of the rook it castles with, like UCI_Chess960, and are tagged
+// with KingSideCastle or QueenSideCastle.  An error is returned if the id
+// is out of range.
+func NewChess960Position(id int) (*Position, error) {
+	if id < 0 || id > 959 {
+		return nil, fmt.Errorf("chess: invalid chess960 position id %d", id)
+	}
+	var rank [8]string
+	n := id
+	// light squared bishop on b, d, f or h then dark squared on a, c, e or g
+	rank[n%4*2+1] = "B"
+	n /= 4
+	rank[n%4*2] = "B"
+	n /= 4
+	empty := func() []int {
+		files := []int{}
+		for f, s := range rank {
+			if s == "" {
+				files = append(files, f)
+			}
+		}
+		return files
+	}
+	rank[empty()[n%6]] = "Q"
+	n /= 6
+	files := empty()
+	rank[files[chess960KnightFiles[n][0]]] = "N"
+	rank[files[chess960KnightFiles[n][1]]] = "N"
+	// the king is placed between the rooks on the remaining files
+	files = empty()
+	rank[files[0]], rank[files[1]], rank[files[2]] = "R", "K", "R"
+
+	white := strings.Join(rank[:], "")
+	castling := strings.ToUpper(fileChars[files[2]:files[2]+1] + fileChars[files[0]:files[0]+1])
+	castling += strings.ToLower(castling)
+	fen := fmt.Sprintf("%s/pppppppp/8/8/8/8/PPPPPPPP/%s w %s - 0 1", strings.ToLower(white), white, castling)
+	return decodeFEN(fen)
+}
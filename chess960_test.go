@@ -0,0 +1,111 @@
+package chess
+
+import "testing"
+
+func TestNewChess960Position(t *testing.T) {
+	tests := []struct {
+		id  int
+		fen string
+	}{
+		{0, "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w HFhf - 0 1"},
+		{518, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1"},
+		{959, "rkrnnqbb/pppppppp/8/8/8/8/PPPPPPPP/RKRNNQBB w CAca - 0 1"},
+	}
+	for _, test := range tests {
+		pos, err := NewChess960Position(test.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos.String() != test.fen {
+			t.Fatalf("expected chess960 position %d to be %s but got %s", test.id, test.fen, pos)
+		}
+		if pos.CastleRights() != "KQkq" {
+			t.Fatalf("expected castle rights KQkq but got %s", pos.CastleRights())
+		}
+	}
+	seen := map[string]bool{}
+	for id := 0; id < 960; id++ {
+		pos, err := NewChess960Position(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[pos.board.String()] = true
+	}
+	if len(seen) != 960 {
+		t.Fatalf("expected 960 unique positions but got %d", len(seen))
+	}
+	for _, id := range []int{-1, 960} {
+		if _, err := NewChess960Position(id); err == nil {
+			t.Fatalf("expected an error for chess960 position %d", id)
+		}
+	}
+}
+
+func TestChess960Castling(t *testing.T) {
+	tests := []struct {
+		fen      string
+		move     string
+		tag      MoveTag
+		expected string
+	}{
+		// the king on g1 doesn't move when castling
+		{"rk5r/8/8/8/8/8/8/R5KR w HAha - 0 1", "g1h1", KingSideCastle, "rk5r/8/8/8/8/8/8/R4RK1 b ha - 1 1"},
+		// the rook on b1 passes square the king lands on
+		{"rk5r/8/8/8/8/8/8/1R3K1R w HBha - 0 1", "f1b1", QueenSideCastle, "rk5r/8/8/8/8/8/8/2KR3R b ha - 1 1"},
+		// the king and rook swap squares
+		{"1r3kr1/8/8/8/8/8/8/R6K b Ag - 0 1", "f8g8", KingSideCastle, "1r3rk1/8/8/8/8/8/8/R6K w A - 1 2"},
+		// X-FEN castle rights use the outermost rook
+		{"r1k2r1r/8/8/8/8/8/8/4K3 b kq - 0 1", "c8a8", QueenSideCastle, "2kr1r1r/8/8/8/8/8/8/4K3 w - - 1 2"},
+	}
+	for _, test := range tests {
+		pos := unsafeFEN(test.fen)
+		m, err := ParseUCIMove(pos, test.move)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !m.HasTag(test.tag) || m.HasTag(Capture) {
+			t.Fatalf("expected move %s to have tag %s but got %s", m, test.tag, m.tags)
+		}
+		if next := pos.Update(m); next.String() != test.expected {
+			t.Fatalf("expected position %s after %s but got %s", test.expected, m, next)
+		}
+	}
+}
+
+func TestChess960InvalidCastling(t *testing.T) {
+	fens := []string{
+		// the rook moving from b1 to d1 opens the a1 queen's line to the king
+		"1k6/8/8/8/8/8/8/qRK5 w B - 0 1",
+		// the king's destination is occupied
+		"1k6/8/8/8/8/8/8/RK4NR w H - 0 1",
+		// the c4 bishop attacks f1 which the king passes over
+		"1k6/8/8/8/2b5/8/8/3KR3 w E - 0 1",
+	}
+	for _, fen := range fens {
+		pos := unsafeFEN(fen)
+		for _, m := range pos.ValidMoves() {
+			if m.HasTag(KingSideCastle) || m.HasTag(QueenSideCastle) {
+				t.Fatalf("expected no castling moves for %s but got %s", fen, m)
+			}
+		}
+	}
+}
+
+func TestChess960Perft(t *testing.T) {
+	// https://www.chessprogramming.org/Chess960_Perft_Results
+	tests := []struct {
+		fen   string
+		nodes []uint64
+	}{
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", []uint64{21, 528, 12189}},
+		{"2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9", []uint64{21, 807, 18002}},
+	}
+	for _, test := range tests {
+		pos := unsafeFEN(test.fen)
+		for i, n := range test.nodes {
+			if got := Perft(pos, i+1); got != n {
+				t.Fatalf("expected perft(%d) of %s to be %d but got %d", i+1, test.fen, n, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package chess
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var clockRe = regexp.MustCompile(`\[%clk\s+(\d+):(\d{1,2}):(\d{1,2}(?:\.\d+)?)\]`)
+
+// ClockTimes returns the clock time remaining after each move from the
+// [%clk h:mm:ss] commands in the move's comments, as used by Lichess and
+// Chess.com.  The result is indexed by moves and a move without a clock
+// command has a clock time of zero.
+func (g *Game) ClockTimes() []time.Duration {
+	times := make([]time.Duration, len(g.moves))
+	for i := range times {
+		if len(g.comments) > i {
+			times[i] = parseClock(g.comments[i])
+		}
+	}
+	return times
+}
+
+// SetClockTime sets the clock time remaining after the move at the given
+// ply, starting from one for the first move, by adding or replacing the
+// [%clk h:mm:ss] command in the move's comments.  Any other comment text
+// is kept.  The game isn't changed if there isn't a move at the ply or
+// the duration is negative.
+func (g *Game) SetClockTime(ply int, d time.Duration) {
+	if ply < 1 || ply > len(g.moves) || d < 0 {
+		return
+	}
+	for len(g.comments) < ply {
+		g.comments = append(g.comments, nil)
+	}
+	clk := "[%clk " + formatClock(d) + "]"
+	comments := append([]string(nil), g.comments[ply-1]...)
+	for i, c := range comments {
+		if clockRe.MatchString(c) {
+			comments[i] = clockRe.ReplaceAllLiteralString(c, clk)
+			g.comments[ply-1] = comments
+			return
+		}
+	}
+	if len(comments) == 0 {
+		comments = append(comments, clk)
+	} else {
+		comments[len(comments)-1] += " " + clk
+	}
+	g.comments[ply-1] = comments
+}
+
+// parseClock returns the clock time of the first [%clk h:mm:ss] command
+// in the comments or zero if there isn't one.
+func parseClock(comments []string) time.Duration {
+	for _, c := range comments {
+		match := clockRe.FindStringSubmatch(c)
+		if match == nil {
+			continue
+		}
+		d, err := time.ParseDuration(match[1] + "h" + match[2] + "m" + match[3] + "s")
+		if err != nil {
+			continue
+		}
+		return d
+	}
+	return 0
+}
+
+// formatClock returns the duration as h:mm:ss with any fraction of a
+// second after the seconds.
+func formatClock(d time.Duration) string {
+	h := d / time.Hour
+	m := d % time.Hour / time.Minute
+	s := d % time.Minute / time.Second
+	str := fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	if frac := d % time.Second; frac != 0 {
+		str += strings.TrimRight(fmt.Sprintf(".%09d", frac), "0")
+	}
+	return str
+}
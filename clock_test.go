@@ -0,0 +1,57 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClockTimes(t *testing.T) {
+	game, err := decodePGN(mustParsePGN("fixtures/pgns/0005.pgn"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	times := game.ClockTimes()
+	if len(times) != len(game.Moves()) {
+		t.Fatalf("expected %d clock times but got %d", len(game.Moves()), len(times))
+	}
+	expected := []time.Duration{300 * time.Second, 300 * time.Second, 301 * time.Second, 302 * time.Second}
+	for i, d := range expected {
+		if times[i] != d {
+			t.Fatalf("expected clock time %s for move %d but got %s", d, i, times[i])
+		}
+	}
+	if times[7] != 305*time.Second {
+		t.Fatalf("expected clock time %s for move 7 but got %s", 305*time.Second, times[7])
+	}
+}
+
+func TestSetClockTime(t *testing.T) {
+	game, err := decodePGN("1. e4 {best by test [%clk 0:02:58]} e5 2. Nf3 {[%clk 0:02:50.5]} *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []time.Duration{178 * time.Second, 0, 170*time.Second + 500*time.Millisecond}
+	for i, d := range game.ClockTimes() {
+		if d != expected[i] {
+			t.Fatalf("expected clock time %s for move %d but got %s", expected[i], i, d)
+		}
+	}
+	game.SetClockTime(1, time.Hour+time.Minute+time.Second)
+	game.SetClockTime(2, 2*time.Minute+250*time.Millisecond)
+	game.SetClockTime(4, time.Minute)
+	if game.Comment(1) != "best by test [%clk 1:01:01]" {
+		t.Fatalf("expected clock to be replaced but got %q", game.Comment(1))
+	}
+	movetext := "1. e4 {best by test [%clk 1:01:01]} 1... e5 {[%clk 0:02:00.25]} 2. Nf3 {[%clk 0:02:50.5]} *"
+	if !strings.HasSuffix(game.String(), movetext) {
+		t.Fatalf("expected movetext %s but got %s", movetext, game.String())
+	}
+	cp, err := decodePGN(game.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cp.ClockTimes()[1]; d != 2*time.Minute+250*time.Millisecond {
+		t.Fatalf("expected clock time %s but got %s", 2*time.Minute+250*time.Millisecond, d)
+	}
+}
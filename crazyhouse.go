@@ -0,0 +1,196 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Crazyhouse positions are read from FENs with a pocket after the board
+// such as rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[Pn] or as a ninth
+// rank rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR/Pn.  Pieces that
+// were promoted are followed by a ~ so they become pawns again when
+// captured.
+
+// pockets holds the number of pieces of each type in each side's pocket
+// indexed by color and then piece type.
+type pockets [3][7]uint8
+
+// dropPieceTypes are the piece types that can be dropped in the order
+// their drops are generated.
+var dropPieceTypes = []PieceType{Queen, Rook, Bishop, Knight, Pawn}
+
+// Pocket returns the number of pieces of each type in the pocket of the
+// given color which can be dropped in Crazyhouse.  Piece types without
+// pieces in the pocket aren't in the map so the map is empty for
+// positions that aren't Crazyhouse positions.
+func (pos *Position) Pocket(c Color) map[PieceType]int {
+	m := map[PieceType]int{}
+	if c != White && c != Black {
+		return m
+	}
+	for _, pt := range dropPieceTypes {
+		if n := pos.pockets[c][pt]; n > 0 {
+			m[pt] = int(n)
+		}
+	}
+	return m
+}
+
+// eachDropMove calls fn with each valid Crazyhouse drop until fn returns
+// false.  It returns false if fn stopped the iteration.  Pawns can't be
+// dropped on the first or eighth rank and drops can't leave the king in
+// check.
+func eachDropMove(pos *Position, fn func(m Move) bool) bool {
+	if pos.variant != Crazyhouse {
+		return true
+	}
+	for _, pt := range dropPieceTypes {
+		if pos.pockets[pos.turn][pt] == 0 {
+			continue
+		}
+		for sq := A1; sq <= H8; sq++ {
+			if !isLegalDrop(pos, pt, sq) {
+				continue
+			}
+			m := NewDropMove(pt, sq)
+			addTags(&m, pos)
+			if !m.HasTag(inCheck) && !fn(m) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isLegalDrop returns true if a piece of the given type can be dropped
+// on sq by the side to move without considering check.
+func isLegalDrop(pos *Position, pt PieceType, sq Square) bool {
+	if pos.variant != Crazyhouse || sq < A1 || sq > H8 || pt == King || pt == NoPieceType {
+		return false
+	}
+	if pos.pockets[pos.turn][pt] == 0 || pos.board.isOccupied(sq) {
+		return false
+	}
+	return pt != Pawn || (sq.Rank() != Rank1 && sq.Rank() != Rank8)
+}
+
+// updatePockets returns the pockets and promoted pieces resulting from
+// the move.  Captured pieces are added to the capturing side's pocket as
+// pawns if they were promoted.
+func (pos *Position) updatePockets(m *Move) (pockets, bitboard) {
+	pkts, promoted := pos.pockets, pos.promoted
+	if pos.variant != Crazyhouse {
+		return pkts, promoted
+	}
+	if m.drop != NoPieceType {
+		pkts[pos.turn][m.drop]--
+		return pkts, promoted
+	}
+	if m.HasTag(KingSideCastle) || m.HasTag(QueenSideCastle) {
+		return pkts, promoted
+	}
+	p := pos.board.Piece(m.s1)
+	captured := pos.board.Piece(m.s2)
+	s1BB, s2BB := bbForSquare(m.s1), bbForSquare(m.s2)
+	switch {
+	case captured != NoPiece && captured.Color() != pos.turn:
+		pt := captured.Type()
+		if promoted&s2BB != 0 {
+			pt = Pawn
+		}
+		pkts[pos.turn][pt]++
+	case p.Type() == Pawn && m.s2 == pos.enPassantSquare:
+		pkts[pos.turn][Pawn]++
+	}
+	moved := promoted&s1BB != 0
+	promoted &= ^(s1BB | s2BB)
+	if moved || m.promo != NoPieceType {
+		promoted |= s2BB
+	}
+	return pkts, promoted
+}
+
+// crazyhouseBoardFEN returns the board part of the position's FEN with
+// promoted pieces marked and the pocket in brackets.
+func (pos *Position) crazyhouseBoardFEN() string {
+	var sb strings.Builder
+	for r := 7; r >= 0; r-- {
+		empty := 0
+		for f := 0; f < 8; f++ {
+			sq := NewSquare(File(f), Rank(r))
+			p := pos.board.Piece(sq)
+			if p == NoPiece {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				fmt.Fprintf(&sb, "%d", empty)
+				empty = 0
+			}
+			sb.WriteString(p.getFENChar())
+			if pos.promoted&bbForSquare(sq) != 0 {
+				sb.WriteString("~")
+			}
+		}
+		if empty > 0 {
+			fmt.Fprintf(&sb, "%d", empty)
+		}
+		if r != 0 {
+			sb.WriteString("/")
+		}
+	}
+	sb.WriteString("[")
+	for _, c := range []Color{White, Black} {
+		for _, pt := range dropPieceTypes {
+			sb.WriteString(strings.Repeat(NewPiece(pt, c).getFENChar(), int(pos.pockets[c][pt])))
+		}
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// decodeCrazyhouseBoard splits the pocket and promoted piece markers
+// from the board part of a Crazyhouse FEN.  It returns the board without
+// them and false if the board doesn't have a pocket.
+func decodeCrazyhouseBoard(boardStr string) (string, pockets, bitboard, bool, error) {
+	var pkts pockets
+	var promoted bitboard
+	pocket := ""
+	if i := strings.Index(boardStr, "["); i >= 0 && strings.HasSuffix(boardStr, "]") {
+		boardStr, pocket = boardStr[:i], boardStr[i+1:len(boardStr)-1]
+	} else if strings.Count(boardStr, "/") == 8 {
+		i := strings.LastIndex(boardStr, "/")
+		boardStr, pocket = boardStr[:i], boardStr[i+1:]
+	} else {
+		return boardStr, pkts, promoted, false, nil
+	}
+	for _, r := range pocket {
+		p, ok := fenPieceMap[string(r)]
+		if !ok || p.Type() == King {
+			return "", pkts, promoted, false, fmt.Errorf("chess: fen invalid pocket %s", pocket)
+		}
+		pkts[p.Color()][p.Type()]++
+	}
+	rankStrs := strings.Split(boardStr, "/")
+	for i, rankStr := range rankStrs {
+		file := 0
+		for _, r := range rankStr {
+			switch {
+			case r == '~':
+				if file == 0 {
+					return "", pkts, promoted, false, fmt.Errorf("chess: fen invalid rank %s", rankStr)
+				}
+				promoted |= bbForSquare(NewSquare(File(file-1), Rank(7-i)))
+			case r >= '1' && r <= '8':
+				file += int(r - '0')
+			default:
+				file++
+			}
+			if file > 8 {
+				return "", pkts, promoted, false, fmt.Errorf("chess: fen invalid rank %s", rankStr)
+			}
+		}
+		rankStrs[i] = strings.Replace(rankStr, "~", "", -1)
+	}
+	return strings.Join(rankStrs, "/"), pkts, promoted, true, nil
+}
@@ -0,0 +1,166 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCrazyhouseFEN(t *testing.T) {
+	tests := []struct {
+		fen      string
+		expected string
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1"},
+		{"rnbqkbnr/ppp1pppp/8/8/8/8/PPPP1PPP/RNBQKBNR/Pp w KQkq - 0 3", "rnbqkbnr/ppp1pppp/8/8/8/8/PPPP1PPP/RNBQKBNR[Pp] w KQkq - 0 3"},
+		{"Q~3k3/8/8/8/8/8/8/4K3[Nqp] b - - 0 30", "Q~3k3/8/8/8/8/8/8/4K3[Nqp] b - - 0 30"},
+	}
+	for _, test := range tests {
+		pos, err := NewPositionFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos.String() != test.expected {
+			t.Fatalf("expected fen %s but got %s", test.expected, pos)
+		}
+		if err := ValidateFEN(test.fen); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pos := unsafeFEN("Q~3k3/8/8/8/8/8/8/4K3[NNqp] b - - 0 30")
+	if n := pos.Pocket(White)[Knight]; n != 2 {
+		t.Fatalf("expected 2 knights in white's pocket but got %d", n)
+	}
+	if len(StartingPosition().Pocket(White)) != 0 {
+		t.Fatal("expected an empty pocket for a standard position")
+	}
+	for _, fen := range []string{
+		"4k3/8/8/8/8/8/8/4K3[K] w - - 0 1",
+		"4k3/8/8/8/8/8/8/4K3[X] w - - 0 1",
+		"~4k3/8/8/8/8/8/8/4K3[] w - - 0 1",
+	} {
+		if _, err := NewPositionFromFEN(fen); err == nil {
+			t.Fatalf("expected an error for fen %s", fen)
+		}
+	}
+}
+
+func TestCrazyhouseCapturesAndDrops(t *testing.T) {
+	fen, err := FEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame(fen)
+	for _, m := range []string{"e4", "d5", "exd5", "Qxd5", "Nc3", "Qa5", "P@d5"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := "rnb1kbnr/ppp1pppp/8/q2P4/8/2N5/PPPP1PPP/R1BQKBNR[p] b KQkq - 3 4"
+	if g.FEN() != expected {
+		t.Fatalf("expected fen %s but got %s", expected, g.FEN())
+	}
+	if !g.Moves()[6].HasTag(Drop) || g.Moves()[6].DropPiece() != Pawn {
+		t.Fatalf("expected move %s to be a pawn drop", g.Moves()[6])
+	}
+	pgn := g.String()
+	if !strings.Contains(pgn, "4. P@d5") {
+		t.Fatalf("expected the drop in the pgn %s", pgn)
+	}
+	opt, err := PGN(strings.NewReader(`[FEN "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1"]
+
+` + pgn[strings.Index(pgn, "1. "):]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp := NewGame(opt); cp.FEN() != expected {
+		t.Fatalf("expected decoded fen %s but got %s", expected, cp.FEN())
+	}
+}
+
+func TestCrazyhouseDropRules(t *testing.T) {
+	// white is in check from the rook on e8 and can only block by dropping
+	// on e2 to e7 since the king has no squares
+	pos := unsafeFEN("4r2k/8/8/8/8/8/3P1P2/3RKR2[NP] w - - 0 1")
+	pos.inCheck = isInCheck(pos)
+	drops := pos.ValidMovesWithTag(Drop)
+	for _, m := range drops {
+		if m.S2().File() != FileE || m.S2().Rank() < Rank2 || m.S2().Rank() > Rank7 {
+			t.Fatalf("expected drops to block the check but got %s", m)
+		}
+	}
+	if len(drops) != 12 || len(pos.ValidMoves()) != 12 {
+		t.Fatalf("expected 12 blocking drops but got %d", len(drops))
+	}
+	if pos.Status() != NoMethod {
+		t.Fatalf("expected a drop to prevent checkmate but got %s", pos.Status())
+	}
+	// pawns can't be dropped on the first or eighth rank
+	pos = unsafeFEN("4k3/8/8/8/8/8/8/4K3[P] w - - 0 1")
+	for _, m := range pos.ValidMovesWithTag(Drop) {
+		if m.S2().Rank() == Rank1 || m.S2().Rank() == Rank8 {
+			t.Fatalf("expected no pawn drop on the back ranks but got %s", m)
+		}
+	}
+	if n := len(pos.ValidMovesWithTag(Drop)); n != 48 {
+		t.Fatalf("expected 48 pawn drops but got %d", n)
+	}
+	m := NewDropMove(Pawn, A8)
+	if pos.IsLegal(&m) {
+		t.Fatal("expected a pawn drop on a8 to be illegal")
+	}
+	m = NewDropMove(Knight, A3)
+	if pos.IsLegal(&m) {
+		t.Fatal("expected a drop of a piece not in the pocket to be illegal")
+	}
+	if pos.Status() != NoMethod {
+		t.Fatalf("expected crazyhouse kings to have sufficient material but got %s", pos.Status())
+	}
+	// a drop can deliver checkmate
+	pos = unsafeFEN("7k/6pp/8/8/8/8/8/K7[R] w - - 0 1")
+	m = NewDropMove(Rook, E8)
+	if !pos.IsLegal(&m) {
+		t.Fatal("expected the rook drop to be legal")
+	}
+	next, err := pos.Move(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Status() != Checkmate || next.Pocket(White)[Rook] != 0 {
+		t.Fatalf("expected the rook drop to checkmate but got %s", next.Status())
+	}
+}
+
+func TestCrazyhousePromotedCapture(t *testing.T) {
+	// the promoted queen returns to the pocket as a pawn
+	pos := unsafeFEN("Q~3k3/1r6/8/8/8/8/8/4K3[] b - - 0 30")
+	next, err := pos.Move(&Move{s1: B7, s2: B8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err = next.Move(&Move{s1: A8, s2: B8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.String() != "1Q~2k3/8/8/8/8/8/8/4K3[R] b - - 0 31" {
+		t.Fatalf("expected the promoted queen to stay marked but got %s", next)
+	}
+	pos = unsafeFEN("Q~3k3/r7/8/8/8/8/8/4K3[] b - - 0 30")
+	next, err = pos.Move(&Move{s1: A7, s2: A8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Pocket(Black)[Pawn] != 1 || next.Pocket(Black)[Queen] != 0 {
+		t.Fatalf("expected a pawn in black's pocket but got %v", next.Pocket(Black))
+	}
+}
+
+func TestStandardHasNoDrops(t *testing.T) {
+	pos := StartingPosition()
+	m := NewDropMove(Knight, E4)
+	if pos.IsLegal(&m) || len(pos.ValidMovesWithTag(Drop)) != 0 {
+		t.Fatal("expected no drops in standard chess")
+	}
+	if _, err := (AlgebraicNotation{}).Decode(pos, "N@e4"); err == nil {
+		t.Fatal("expected an error decoding a drop in standard chess")
+	}
+}
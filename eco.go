@@ -0,0 +1,148 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ecoOpenings is a compact table of common openings from the
+// Encyclopaedia of Chess Openings.  The opening package has the full
+// table along with tools for exploring it.
+var ecoOpenings = []struct {
+	code  string
+	name  string
+	moves string
+}{
+	{"A00", "Polish Opening", "b4"},
+	{"A01", "Nimzo-Larsen Attack", "b3"},
+	{"A02", "Bird Opening", "f4"},
+	{"A04", "Zukertort Opening", "Nf3"},
+	{"A09", "Réti Opening", "Nf3 d5 c4"},
+	{"A10", "English Opening", "c4"},
+	{"A20", "English Opening: King's English Variation", "c4 e5"},
+	{"A30", "English Opening: Symmetrical Variation", "c4 c5"},
+	{"A40", "Queen's Pawn Game", "d4"},
+	{"A43", "Benoni Defense: Old Benoni", "d4 c5"},
+	{"A45", "Indian Defense", "d4 Nf6"},
+	{"A56", "Benoni Defense", "d4 Nf6 c4 c5"},
+	{"A57", "Benko Gambit", "d4 Nf6 c4 c5 d5 b5"},
+	{"A60", "Benoni Defense: Modern Variation", "d4 Nf6 c4 c5 d5 e6"},
+	{"A80", "Dutch Defense", "d4 f5"},
+	{"B00", "King's Pawn", "e4"},
+	{"B01", "Scandinavian Defense", "e4 d5"},
+	{"B02", "Alekhine Defense", "e4 Nf6"},
+	{"B06", "Modern Defense", "e4 g6"},
+	{"B07", "Pirc Defense", "e4 d6 d4 Nf6 Nc3 g6"},
+	{"B10", "Caro-Kann Defense", "e4 c6"},
+	{"B12", "Caro-Kann Defense: Advance Variation", "e4 c6 d4 d5 e5"},
+	{"B20", "Sicilian Defense", "e4 c5"},
+	{"B22", "Sicilian Defense: Alapin Variation", "e4 c5 c3"},
+	{"B23", "Sicilian Defense: Closed", "e4 c5 Nc3"},
+	{"B30", "Sicilian Defense: Old Sicilian", "e4 c5 Nf3 Nc6"},
+	{"B32", "Sicilian Defense: Open", "e4 c5 Nf3 Nc6 d4 cxd4 Nxd4"},
+	{"B40", "Sicilian Defense: French Variation", "e4 c5 Nf3 e6"},
+	{"B50", "Sicilian Defense: Modern Variations", "e4 c5 Nf3 d6"},
+	{"B70", "Sicilian Defense: Dragon Variation", "e4 c5 Nf3 d6 d4 cxd4 Nxd4 Nf6 Nc3 g6"},
+	{"B90", "Sicilian Defense: Najdorf Variation", "e4 c5 Nf3 d6 d4 cxd4 Nxd4 Nf6 Nc3 a6"},
+	{"C00", "French Defense", "e4 e6"},
+	{"C02", "French Defense: Advance Variation", "e4 e6 d4 d5 e5"},
+	{"C03", "French Defense: Tarrasch Variation", "e4 e6 d4 d5 Nd2"},
+	{"C10", "French Defense: Paulsen Variation", "e4 e6 d4 d5 Nc3"},
+	{"C20", "King's Pawn Game", "e4 e5"},
+	{"C23", "Bishop's Opening", "e4 e5 Bc4"},
+	{"C25", "Vienna Game", "e4 e5 Nc3"},
+	{"C30", "King's Gambit", "e4 e5 f4"},
+	{"C33", "King's Gambit Accepted", "e4 e5 f4 exf4"},
+	{"C40", "King's Knight Opening", "e4 e5 Nf3"},
+	{"C41", "Philidor Defense", "e4 e5 Nf3 d6"},
+	{"C42", "Russian Game", "e4 e5 Nf3 Nf6"},
+	{"C44", "King's Knight Opening: Normal Variation", "e4 e5 Nf3 Nc6"},
+	{"C44", "Scotch Game", "e4 e5 Nf3 Nc6 d4"},
+	{"C46", "Three Knights Opening", "e4 e5 Nf3 Nc6 Nc3"},
+	{"C47", "Four Knights Game", "e4 e5 Nf3 Nc6 Nc3 Nf6"},
+	{"C50", "Italian Game", "e4 e5 Nf3 Nc6 Bc4"},
+	{"C50", "Giuoco Piano", "e4 e5 Nf3 Nc6 Bc4 Bc5"},
+	{"C51", "Italian Game: Evans Gambit", "e4 e5 Nf3 Nc6 Bc4 Bc5 b4"},
+	{"C55", "Italian Game: Two Knights Defense", "e4 e5 Nf3 Nc6 Bc4 Nf6"},
+	{"C60", "Ruy Lopez", "e4 e5 Nf3 Nc6 Bb5"},
+	{"C65", "Ruy Lopez: Berlin Defense", "e4 e5 Nf3 Nc6 Bb5 Nf6"},
+	{"C68", "Ruy Lopez: Exchange Variation", "e4 e5 Nf3 Nc6 Bb5 a6 Bxc6"},
+	{"C70", "Ruy Lopez: Morphy Defense", "e4 e5 Nf3 Nc6 Bb5 a6"},
+	{"C84", "Ruy Lopez: Closed", "e4 e5 Nf3 Nc6 Bb5 a6 Ba4 Nf6 O-O Be7"},
+	{"D00", "Queen's Pawn Game", "d4 d5"},
+	{"D00", "Queen's Pawn Game: Mason Variation", "d4 d5 Bf4"},
+	{"D06", "Queen's Gambit", "d4 d5 c4"},
+	{"D07", "Queen's Gambit Declined: Chigorin Defense", "d4 d5 c4 Nc6"},
+	{"D10", "Slav Defense", "d4 d5 c4 c6"},
+	{"D20", "Queen's Gambit Accepted", "d4 d5 c4 dxc4"},
+	{"D30", "Queen's Gambit Declined", "d4 d5 c4 e6"},
+	{"D80", "Grünfeld Defense", "d4 Nf6 c4 g6 Nc3 d5"},
+	{"E00", "Catalan Opening", "d4 Nf6 c4 e6 g3"},
+	{"E11", "Bogo-Indian Defense", "d4 Nf6 c4 e6 Nf3 Bb4+"},
+	{"E12", "Queen's Indian Defense", "d4 Nf6 c4 e6 Nf3 b6"},
+	{"E20", "Nimzo-Indian Defense", "d4 Nf6 c4 e6 Nc3 Bb4"},
+	{"E61", "King's Indian Defense", "d4 Nf6 c4 g6 Nc3"},
+}
+
+// ecoNode is a node in the tree of ecoOpenings keyed by the moves in UCI
+// notation.  Nodes that end an opening have its code and name.
+type ecoNode struct {
+	code     string
+	name     string
+	children map[string]*ecoNode
+}
+
+var (
+	ecoOnce sync.Once
+	ecoRoot *ecoNode
+)
+
+// ecoTree returns the tree of ecoOpenings which is built on first use.
+func ecoTree() *ecoNode {
+	ecoOnce.Do(func() {
+		ecoRoot = &ecoNode{children: map[string]*ecoNode{}}
+		for _, o := range ecoOpenings {
+			pos := StartingPosition()
+			n := ecoRoot
+			for _, s := range strings.Fields(o.moves) {
+				m, err := AlgebraicNotation{}.Decode(pos, s)
+				if err != nil {
+					panic(fmt.Sprintf("chess: invalid eco opening %s %s: %s", o.code, o.name, err))
+				}
+				child, ok := n.children[m.UCI()]
+				if !ok {
+					child = &ecoNode{children: map[string]*ecoNode{}}
+					n.children[m.UCI()] = child
+				}
+				n = child
+				pos = pos.Update(m)
+			}
+			n.code, n.name = o.code, o.name
+		}
+	})
+	return ecoRoot
+}
+
+// ECO returns the Encyclopaedia of Chess Openings code and name of the
+// game's opening and true, or false if the game doesn't start with a
+// known opening.  The opening is the one matching the longest prefix of
+// the game's moves from a compact table of common openings so
+// transpositions aren't recognized.  Games that don't start from the
+// standard starting position have no opening.
+func (g *Game) ECO() (code, name string, ok bool) {
+	if len(g.positions) == 0 || !g.positions[0].SamePosition(StartingPosition()) {
+		return "", "", false
+	}
+	n := ecoTree()
+	for _, m := range g.moves {
+		n = n.children[m.UCI()]
+		if n == nil {
+			break
+		}
+		if n.code != "" {
+			code, name, ok = n.code, n.name, true
+		}
+	}
+	return code, name, ok
+}
@@ -0,0 +1,61 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGameECO(t *testing.T) {
+	tests := []struct {
+		moves string
+		code  string
+		name  string
+		ok    bool
+	}{
+		{"", "", "", false},
+		{"e4", "B00", "King's Pawn", true},
+		{"e4 c5 Nf3 d6 d4 cxd4 Nxd4 Nf6 Nc3 a6 Be3", "B90", "Sicilian Defense: Najdorf Variation", true},
+		// the longest known prefix is used once the game leaves the table
+		{"e4 e5 Nf3 Nc6 Bb5 a6 Ba4 Nf6 O-O b5", "C70", "Ruy Lopez: Morphy Defense", true},
+		{"d4 Nf6 c4 g6 Nc3 d5", "D80", "Grünfeld Defense", true},
+		{"a3 e5", "", "", false},
+	}
+	for _, test := range tests {
+		g := NewGame()
+		for _, m := range strings.Fields(test.moves) {
+			if err := g.MoveStr(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		code, name, ok := g.ECO()
+		if code != test.code || name != test.name || ok != test.ok {
+			t.Fatalf("expected %s %s %t for %s but got %s %s %t", test.code, test.name, test.ok, test.moves, code, name, ok)
+		}
+	}
+	fen, err := FEN("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame(fen)
+	if err := g.MoveStr("c5"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := g.ECO(); ok {
+		t.Fatal("expected no opening for a game from a fen")
+	}
+}
+
+func TestECOOpenings(t *testing.T) {
+	for _, o := range ecoOpenings {
+		g := NewGame()
+		for _, m := range strings.Fields(o.moves) {
+			if err := g.MoveStr(m); err != nil {
+				t.Fatalf("opening %s %s: %s", o.code, o.name, err)
+			}
+		}
+		code, name, ok := g.ECO()
+		if !ok || code != o.code || name != o.name {
+			t.Fatalf("expected %s %s but got %s %s", o.code, o.name, code, name)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package chess
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// KnownResult returns the result of the position when it is known
+// without searching and true, or NoMethod and false otherwise.  Finished
+// positions, including those without sufficient material to checkmate,
+// return their Status.  King and pawn versus king positions played with
+// standard rules are looked up in a table generated on first use and
+// return KnownWin if the side with the pawn wins with best play, whichever
+// side is to move, or KnownDraw if it can't force a win.
+func (pos *Position) KnownResult() (Method, bool) {
+	if method := pos.Status(); method != NoMethod {
+		return method, true
+	}
+	if win, ok := kpkProbe(pos); ok {
+		if win {
+			return KnownWin, true
+		}
+		return KnownDraw, true
+	}
+	return NoMethod, false
+}
+
+// The king and pawn versus king table is indexed by the side to move,
+// the squares of both kings and the pawn's square with the side with the
+// pawn as white and the pawn on the a to d files.
+const (
+	kpkInvalid uint8 = 0
+	kpkUnknown uint8 = 1
+	kpkDraw    uint8 = 2
+	kpkWin     uint8 = 4
+
+	kpkSize = 2 * 64 * 64 * 4 * 6
+)
+
+var (
+	kpkOnce  sync.Once
+	kpkTable []uint8
+	// kpkKing holds the squares next to each square with bit i set for
+	// square i.
+	kpkKing [64]uint64
+)
+
+// kpkProbe returns whether the side with the pawn wins and true if the
+// position is a valid king and pawn versus king position.
+func kpkProbe(pos *Position) (bool, bool) {
+	// the table only holds for standard rules without pieces to drop
+	if (pos.variant != Standard && pos.variant != Chess960) || pos.pockets != (pockets{}) {
+		return false, false
+	}
+	b := pos.board
+	if b.bbWhiteQueen|b.bbWhiteRook|b.bbWhiteBishop|b.bbWhiteKnight|
+		b.bbBlackQueen|b.bbBlackRook|b.bbBlackBishop|b.bbBlackKnight != 0 {
+		return false, false
+	}
+	strong := White
+	pawns := append(b.bbWhitePawn.squares(), b.bbBlackPawn.squares()...)
+	if len(pawns) != 1 {
+		return false, false
+	}
+	if b.bbBlackPawn != 0 {
+		strong = Black
+	}
+	sk, wk, psq := b.kingSquare(strong), b.kingSquare(strong.Other()), pawns[0]
+	if sk == NoSquare || wk == NoSquare {
+		return false, false
+	}
+	// normalize so the side with the pawn is white with the pawn on the a to d files
+	normalize := func(sq Square) int {
+		f, r := int(sq.File()), int(sq.Rank())
+		if strong == Black {
+			r = 7 - r
+		}
+		if psq.File() > FileD {
+			f = 7 - f
+		}
+		return r*8 + f
+	}
+	p := normalize(psq)
+	if p < 8 || p >= 56 {
+		return false, false
+	}
+	kpkOnce.Do(initKPK)
+	switch kpkTable[kpkIndex(pos.turn != strong, normalize(sk), normalize(wk), p)] {
+	case kpkWin:
+		return true, true
+	case kpkInvalid:
+		return false, false
+	}
+	return false, true
+}
+
+func kpkIndex(weakToMove bool, sk, wk, p int) int {
+	stm := 0
+	if weakToMove {
+		stm = 1
+	}
+	return stm | wk<<1 | sk<<7 | (p%8)<<13 | (p/8-1)<<15
+}
+
+// initKPK classifies every position and then repeatedly classifies the
+// unknown positions from their children until none change.  Positions
+// that are still unknown can't be won so they are draws.
+func initKPK() {
+	for sq := range kpkKing {
+		for df := -1; df <= 1; df++ {
+			for dr := -1; dr <= 1; dr++ {
+				f, r := sq%8+df, sq/8+dr
+				if (df != 0 || dr != 0) && f >= 0 && f < 8 && r >= 0 && r < 8 {
+					kpkKing[sq] |= 1 << uint(r*8+f)
+				}
+			}
+		}
+	}
+	db := make([]uint8, kpkSize)
+	for i := range db {
+		db[i] = kpkClassify(nil, i)
+	}
+	for changed := true; changed; {
+		changed = false
+		for i, r := range db {
+			if r != kpkUnknown {
+				continue
+			}
+			if r = kpkClassify(db, i); r != kpkUnknown {
+				db[i] = r
+				changed = true
+			}
+		}
+	}
+	for i, r := range db {
+		if r == kpkUnknown {
+			db[i] = kpkDraw
+		}
+	}
+	kpkTable = db
+}
+
+// kpkClassify returns the result of the position at index i.  Without a
+// table the position is classified by the rules alone and with one it is
+// classified from the results of its children.
+func kpkClassify(db []uint8, i int) uint8 {
+	weakToMove := i&1 == 1
+	wk, sk := (i>>1)&63, (i>>7)&63
+	p := (i>>13)&3 + ((i>>15)+1)*8
+	if db == nil {
+		switch {
+		case sk == wk || kpkDistance(sk, wk) <= 1 || sk == p || wk == p:
+			return kpkInvalid
+		case !weakToMove && kpkPawnAttacks(p)&(1<<uint(wk)) != 0:
+			return kpkInvalid
+		case !weakToMove && p/8 == 6 && sk != p+8 &&
+			(kpkDistance(wk, p+8) > 1 || kpkDistance(sk, p+8) <= 1):
+			return kpkWin
+		case weakToMove && (kpkKing[wk]&^(kpkKing[sk]|kpkPawnAttacks(p)) == 0 ||
+			kpkDistance(wk, p) <= 1 && kpkDistance(sk, p) > 1):
+			return kpkDraw
+		}
+		return kpkUnknown
+	}
+	r := uint8(0)
+	if weakToMove {
+		for bb := kpkKing[wk] &^ (kpkKing[sk] | kpkPawnAttacks(p)); bb != 0; bb &= bb - 1 {
+			r |= db[kpkIndex(false, sk, bits.TrailingZeros64(bb), p)]
+		}
+		switch {
+		case r&kpkDraw != 0:
+			return kpkDraw
+		case r&kpkUnknown != 0:
+			return kpkUnknown
+		}
+		return kpkWin
+	}
+	for bb := kpkKing[sk] &^ (kpkKing[wk] | 1<<uint(p)); bb != 0; bb &= bb - 1 {
+		r |= db[kpkIndex(true, bits.TrailingZeros64(bb), wk, p)]
+	}
+	if p/8 < 6 {
+		r |= db[kpkIndex(true, sk, wk, p+8)]
+		if p/8 == 1 && p+8 != sk && p+8 != wk {
+			r |= db[kpkIndex(true, sk, wk, p+16)]
+		}
+	}
+	switch {
+	case r&kpkWin != 0:
+		return kpkWin
+	case r&kpkUnknown != 0:
+		return kpkUnknown
+	}
+	return kpkDraw
+}
+
+// kpkPawnAttacks returns the squares attacked by a white pawn on sq.
+func kpkPawnAttacks(sq int) uint64 {
+	bb := uint64(0)
+	if sq%8 > 0 {
+		bb |= 1 << uint(sq+7)
+	}
+	if sq%8 < 7 {
+		bb |= 1 << uint(sq+9)
+	}
+	return bb
+}
+
+func kpkDistance(s1, s2 int) int {
+	df, dr := s1%8-s2%8, s1/8-s2/8
+	if df < 0 {
+		df = -df
+	}
+	if dr < 0 {
+		dr = -dr
+	}
+	if df > dr {
+		return df
+	}
+	return dr
+}
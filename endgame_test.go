@@ -0,0 +1,71 @@
+package chess
+
+import "testing"
+
+func TestKnownResult(t *testing.T) {
+	tests := []struct {
+		fen    string
+		method Method
+		known  bool
+	}{
+		// king on the sixth rank in front of its pawn wins
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", KnownWin, true},
+		{"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1", KnownWin, true},
+		// the defending king in front of a rook pawn draws
+		{"k7/8/8/8/8/8/P7/K7 w - - 0 1", KnownDraw, true},
+		// the defending king in front of a pawn its king is behind draws
+		{"4k3/8/4P3/4K3/8/8/8/8 w - - 0 1", KnownDraw, true},
+		{"4k3/8/4P3/4K3/8/8/8/8 b - - 0 1", KnownDraw, true},
+		// the pawn outside the square of the king promotes
+		{"8/8/8/3P4/8/8/8/k6K w - - 0 1", KnownWin, true},
+		{"8/8/8/3P4/8/8/8/k6K b - - 0 1", KnownWin, true},
+		{"8/8/8/3P4/k7/8/8/7K b - - 0 1", KnownDraw, true},
+		{"8/8/8/3P4/k7/8/8/7K w - - 0 1", KnownWin, true},
+		// black pawn positions are mirrored
+		{"8/8/8/8/4p3/4k3/8/4K3 b - - 0 1", KnownWin, true},
+		{"k7/p7/8/8/8/8/8/K7 b - - 0 1", KnownDraw, true},
+		{"8/8/8/8/8/8/k6K/8 w - - 0 1", InsufficientMaterial, true},
+		{"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", Stalemate, true},
+		{"4k3/8/8/8/8/8/8/Q3K3 w - - 0 1", NoMethod, false},
+		{"4k3/4p3/8/8/8/8/4P3/4K3 w - - 0 1", NoMethod, false},
+	}
+	if KnownWin.String() != "KnownWin" || KnownDraw.String() != "KnownDraw" {
+		t.Fatalf("expected the method names but got %s and %s", KnownWin, KnownDraw)
+	}
+	for _, test := range tests {
+		if test.method == KnownDraw && unsafeFEN(test.fen).InsufficientMaterial() {
+			t.Fatalf("expected %s to have sufficient material", test.fen)
+		}
+		method, known := unsafeFEN(test.fen).KnownResult()
+		if method != test.method || known != test.known {
+			t.Fatalf("expected %s to have known result %s %t but got %s %t", test.fen, test.method, test.known, method, known)
+		}
+	}
+	// the table isn't used for variants where the pawn side's material
+	// doesn't decide the result
+	variants := []struct {
+		v   Variant
+		fen string
+	}{
+		{KingOfTheHill, "k7/8/8/8/8/8/P7/K7 w - - 0 1"},
+		{ThreeCheck, "k7/8/8/8/8/8/P7/K7 w - - 0 1"},
+		{Crazyhouse, "k7/8/8/8/8/8/P7/K7[q] w - - 0 1"},
+		{Crazyhouse, "k7/8/8/8/8/8/P7/K7[] w - - 0 1"},
+	}
+	for _, test := range variants {
+		pos, err := NewVariantPosition(test.v, test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if method, known := pos.KnownResult(); method != NoMethod || known {
+			t.Fatalf("expected %s %s to have no known result but got %s %t", test.v, test.fen, method, known)
+		}
+	}
+	pos, err := NewVariantPosition(Chess960, "k7/8/8/8/8/8/P7/K7 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method, known := pos.KnownResult(); method != KnownDraw || !known {
+		t.Fatalf("expected the table to be used for Chess960 but got %s %t", method, known)
+	}
+}
@@ -6,13 +6,24 @@ func (engine) CalcMoves(pos *Position, first bool) []*Move {
 	// generate possible moves
 	moves := standardMoves(pos, first)
 	// return moves including castles
-	return append(moves, castleMoves(pos)...)
+	moves = append(moves, castleMoves(pos)...)
+	if first && len(moves) > 0 {
+		return moves
+	}
+	eachDropMove(pos, func(m Move) bool {
+		moves = append(moves, &m)
+		return !first
+	})
+	return moves
 }
 
 func (engine) Status(pos *Position) Method {
+	if method := pos.variantStatus(); method != NoMethod {
+		return method
+	}
 	hasMove := false
-	if pos.validMoves != nil {
-		hasMove = len(pos.validMoves) > 0
+	if moves := pos.cachedMoves(); moves != nil {
+		hasMove = len(moves) > 0
 	} else {
 		hasMove = len(engine{}.CalcMoves(pos, true)) > 0
 	}
@@ -21,6 +32,12 @@ func (engine) Status(pos *Position) Method {
 	} else if pos.inCheck && !hasMove {
 		return Checkmate
 	}
+	if !pos.hasSufficientMaterial() {
+		return InsufficientMaterial
+	}
+	if pos.halfMoveClock >= 150 {
+		return SeventyFiveMoveRule
+	}
 	return NoMethod
 }
 
@@ -29,12 +46,23 @@ var (
 )
 
 func standardMoves(pos *Position, first bool) []*Move {
+	moves := []*Move{}
+	eachStandardMove(pos, ^bitboard(0), func(m Move) bool {
+		moves = append(moves, &m)
+		return !first
+	})
+	return moves
+}
+
+// eachStandardMove calls fn with each valid move besides castling to a
+// square in bbTargets until fn returns false.  It returns false if fn
+// stopped the iteration.
+func eachStandardMove(pos *Position, bbTargets bitboard, fn func(m Move) bool) bool {
 	// compute allowed destination bitboard
-	bbAllowed := ^pos.board.whiteSqs
+	bbAllowed := ^pos.board.whiteSqs & bbTargets
 	if pos.Turn() == Black {
-		bbAllowed = ^pos.board.blackSqs
+		bbAllowed = ^pos.board.blackSqs & bbTargets
 	}
-	moves := []*Move{}
 	// iterate through pieces to find possible moves
 	for _, p := range allPieces {
 		if pos.Turn() != p.Color() {
@@ -61,43 +89,103 @@ func standardMoves(pos *Position, first bool) []*Move {
 				// add promotions if pawn on promo square
 				if (p == WhitePawn && Square(s2).Rank() == Rank8) || (p == BlackPawn && Square(s2).Rank() == Rank1) {
 					for _, pt := range promoPieceTypes {
-						m := &Move{s1: Square(s1), s2: Square(s2), promo: pt}
-						addTags(m, pos)
+						m := Move{s1: Square(s1), s2: Square(s2), promo: pt}
+						addTags(&m, pos)
 						// filter out moves that put king into check
-						if !m.HasTag(inCheck) {
-							moves = append(moves, m)
-							if first {
-								return moves
-							}
+						if !m.HasTag(inCheck) && !fn(m) {
+							return false
 						}
 					}
 				} else {
-					m := &Move{s1: Square(s1), s2: Square(s2)}
-					addTags(m, pos)
+					m := Move{s1: Square(s1), s2: Square(s2)}
+					addTags(&m, pos)
 					// filter out moves that put king into check
-					if !m.HasTag(inCheck) {
-						moves = append(moves, m)
-						if first {
-							return moves
-						}
+					if !m.HasTag(inCheck) && !fn(m) {
+						return false
 					}
 				}
 			}
 		}
 	}
-	return moves
+	return true
+}
+
+// isLegalMove returns true if m is one of the position's valid moves
+// without generating the moves of the other pieces.
+func isLegalMove(pos *Position, m *Move) bool {
+	_, ok := legalMove(pos, m)
+	return ok
+}
+
+// legalMove returns m with the tags it has as one of the position's valid
+// moves and true, or false if it isn't valid.  Only the moves of the
+// piece being moved are generated.
+func legalMove(pos *Position, m *Move) (Move, bool) {
+	if m == nil || m.s1 < A1 || m.s1 > H8 || m.s2 < A1 || m.s2 > H8 {
+		return Move{}, false
+	}
+	if m.drop != NoPieceType {
+		if m.s1 != m.s2 || m.promo != NoPieceType || !isLegalDrop(pos, m.drop, m.s2) {
+			return Move{}, false
+		}
+		cp := NewDropMove(m.drop, m.s2)
+		addTags(&cp, pos)
+		return cp, !cp.HasTag(inCheck)
+	}
+	p := pos.board.Piece(m.s1)
+	if p.Color() != pos.Turn() {
+		return Move{}, false
+	}
+	if p.Type() == King && m.promo == NoPieceType {
+		for _, c := range castleMoves(pos) {
+			if c.Equal(*m) {
+				return *c, true
+			}
+		}
+	}
+	bbAllowed := ^pos.board.whiteSqs
+	if pos.Turn() == Black {
+		bbAllowed = ^pos.board.blackSqs
+	}
+	if bbForPossibleMoves(pos, p.Type(), m.s1)&bbAllowed&bbForSquare(m.s2) == 0 {
+		return Move{}, false
+	}
+	// pawns reaching the last rank must promote and nothing else can
+	promotes := (p == WhitePawn && m.s2.Rank() == Rank8) || (p == BlackPawn && m.s2.Rank() == Rank1)
+	validPromo := m.promo == NoPieceType
+	if promotes {
+		validPromo = false
+		for _, pt := range promoPieceTypes {
+			validPromo = validPromo || m.promo == pt
+		}
+	}
+	if !validPromo {
+		return Move{}, false
+	}
+	cp := Move{s1: m.s1, s2: m.s2, promo: m.promo}
+	addTags(&cp, pos)
+	return cp, !cp.HasTag(inCheck)
 }
 
 func addTags(m *Move, pos *Position) {
 	p := pos.board.Piece(m.s1)
-	if pos.board.isOccupied(m.s2) {
+	castle := m.HasTag(KingSideCastle) || m.HasTag(QueenSideCastle)
+	if pos.board.isOccupied(m.s2) && !castle {
 		m.addTag(Capture)
-	} else if m.s2 == pos.enPassantSquare && p.Type() == Pawn {
+	} else if m.s2 == pos.enPassantSquare && p.Type() == Pawn && m.s1.File() != m.s2.File() {
+		// only the diagonal capture onto the en passant square is en
+		// passant, never a push such as the double push that set it up
 		m.addTag(EnPassant)
 	}
-	// determine if in check after move (makes move invalid)
-	cp := pos.copy()
-	cp.board.update(m)
+	// determine if in check after move (makes move invalid) using a
+	// board and position that stay on the stack
+	b := *pos.board
+	cp := &Position{board: &b, turn: pos.turn}
+	if m.drop != NoPieceType {
+		cp.board.drop(NewPiece(m.drop, pos.turn), m.s2)
+	} else {
+		cp.board.update(m)
+	}
 	if isInCheck(cp) {
 		m.addTag(inCheck)
 	}
@@ -182,6 +270,35 @@ func squaresAreAttacked(pos *Position, sqs ...Square) bool {
 	return false
 }
 
+// attackersBB returns a bitboard of the squares with pieces of color c
+// that attack sq.  Sliding attacks are blocked by any occupied square.
+func attackersBB(b *Board, sq Square, c Color) bitboard {
+	return attackersOccBB(b, ^b.emptySqs, sq, c)
+}
+
+// attackersOccBB is like attackersBB but only considers the pieces on
+// the occupied squares which lets removed pieces reveal x-ray attacks.
+func attackersOccBB(b *Board, occ bitboard, sq Square, c Color) bitboard {
+	dia := diaAttack(occ, sq)
+	hv := hvAttack(occ, sq)
+	bb := (dia | hv) & b.bbForPiece(NewPiece(Queen, c))
+	bb |= hv & b.bbForPiece(NewPiece(Rook, c))
+	bb |= dia & b.bbForPiece(NewPiece(Bishop, c))
+	bb |= bbKnightMoves[sq] & b.bbForPiece(NewPiece(Knight, c))
+	bb |= bbKingMoves[sq] & b.bbForPiece(NewPiece(King, c))
+	// pawns attack diagonally forward so look diagonally backward from sq
+	dr := -1
+	if c == Black {
+		dr = 1
+	}
+	for _, df := range []int{-1, 1} {
+		if s, ok := sq.Offset(df, dr); ok {
+			bb |= bbForSquare(s) & b.bbForPiece(NewPiece(Pawn, c))
+		}
+	}
+	return bb & occ
+}
+
 func bbForPossibleMoves(pos *Position, pt PieceType, sq Square) bitboard {
 	switch pt {
 	case King:
@@ -200,54 +317,86 @@ func bbForPossibleMoves(pos *Position, pt PieceType, sq Square) bitboard {
 	return bitboard(0)
 }
 
-// TODO can calc isInCheck twice
 func castleMoves(pos *Position) []*Move {
 	moves := []*Move{}
-	kingSide := pos.castleRights.CanCastle(pos.Turn(), KingSide)
-	queenSide := pos.castleRights.CanCastle(pos.Turn(), QueenSide)
-	// white king side
-	if pos.turn == White && kingSide &&
-		(^pos.board.emptySqs&(bbForSquare(F1)|bbForSquare(G1))) == 0 &&
-		!squaresAreAttacked(pos, F1, G1) &&
-		!pos.inCheck {
-		m := &Move{s1: E1, s2: G1}
-		m.addTag(KingSideCastle)
-		addTags(m, pos)
-		moves = append(moves, m)
-	}
-	// white queen side
-	if pos.turn == White && queenSide &&
-		(^pos.board.emptySqs&(bbForSquare(B1)|bbForSquare(C1)|bbForSquare(D1))) == 0 &&
-		!squaresAreAttacked(pos, C1, D1) &&
-		!pos.inCheck {
-		m := &Move{s1: E1, s2: C1}
-		m.addTag(QueenSideCastle)
-		addTags(m, pos)
-		moves = append(moves, m)
-	}
-	// black king side
-	if pos.turn == Black && kingSide &&
-		(^pos.board.emptySqs&(bbForSquare(F8)|bbForSquare(G8))) == 0 &&
-		!squaresAreAttacked(pos, F8, G8) &&
-		!pos.inCheck {
-		m := &Move{s1: E8, s2: G8}
-		m.addTag(KingSideCastle)
-		addTags(m, pos)
-		moves = append(moves, m)
-	}
-	// black queen side
-	if pos.turn == Black && queenSide &&
-		(^pos.board.emptySqs&(bbForSquare(B8)|bbForSquare(C8)|bbForSquare(D8))) == 0 &&
-		!squaresAreAttacked(pos, C8, D8) &&
-		!pos.inCheck {
-		m := &Move{s1: E8, s2: C8}
-		m.addTag(QueenSideCastle)
-		addTags(m, pos)
-		moves = append(moves, m)
+	if pos.inCheck {
+		return moves
+	}
+	for _, side := range []Side{KingSide, QueenSide} {
+		if m := castleMove(pos, side); m != nil {
+			moves = append(moves, m)
+		}
 	}
 	return moves
 }
 
+// castleMove returns the castling move for the side to move on the given
+// side of the board or nil if it isn't valid.  The squares the king and
+// rook travel over must be empty besides the king and rook themselves, and
+// the squares the king travels over can't be attacked.
+func castleMove(pos *Position, side Side) *Move {
+	c := pos.Turn()
+	if !pos.castleRights.CanCastle(c, side) {
+		return nil
+	}
+	r := Rank1
+	if c == Black {
+		r = Rank8
+	}
+	kingFrom := NewSquare(FileE, r)
+	if pos.chess960 {
+		kingFrom = pos.board.kingSquare(c)
+	}
+	rookFrom := pos.castleRookSquare(c, side)
+	if pos.board.Piece(kingFrom) != NewPiece(King, c) || pos.board.Piece(rookFrom) != NewPiece(Rook, c) {
+		return nil
+	}
+	kingTo, rookTo, tag := NewSquare(FileG, r), NewSquare(FileF, r), KingSideCastle
+	if side == QueenSide {
+		kingTo, rookTo, tag = NewSquare(FileC, r), NewSquare(FileD, r), QueenSideCastle
+	}
+	path := (bbForRankSpan(kingFrom, kingTo) | bbForRankSpan(rookFrom, rookTo)) & ^(bbForSquare(kingFrom) | bbForSquare(rookFrom))
+	if ^pos.board.emptySqs&path != 0 {
+		return nil
+	}
+	kingPath := []Square{}
+	for sq := kingFrom; sq != kingTo; {
+		if sq < kingTo {
+			sq++
+		} else {
+			sq--
+		}
+		kingPath = append(kingPath, sq)
+	}
+	if squaresAreAttacked(pos, kingPath...) {
+		return nil
+	}
+	m := &Move{s1: kingFrom, s2: kingTo}
+	if pos.chess960 {
+		m.s2 = rookFrom
+	}
+	m.addTag(tag)
+	addTags(m, pos)
+	// in Chess960 the rook can leave a line to the king's destination open
+	if m.HasTag(inCheck) {
+		return nil
+	}
+	return m
+}
+
+// bbForRankSpan returns a bitboard of the squares from s1 to s2
+// inclusive which must be on the same rank.
+func bbForRankSpan(s1, s2 Square) bitboard {
+	if s1 > s2 {
+		s1, s2 = s2, s1
+	}
+	var bb bitboard
+	for sq := s1; sq <= s2; sq++ {
+		bb |= bbForSquare(sq)
+	}
+	return bb
+}
+
 func pawnMoves(pos *Position, sq Square) bitboard {
 	bb := bbForSquare(sq)
 	var bbEnPassant bitboard
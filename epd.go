@@ -0,0 +1,117 @@
+package chess
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	epdRe       = regexp.MustCompile(`^(\S+)\s+(\S+)\s+(\S+)\s+(\S+)(?:\s+(.*))?$`)
+	epdOpcodeRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,14}$`)
+)
+
+// ParseEPD decodes the EPD (Extended Position Description) record s and
+// returns its position and operations.  The operations map each opcode,
+// such as "bm", "am", "id" or "ce", to its operands with the quotes
+// removed from a single string operand.  The hmvc and fmvn operations
+// set the position's half move clock and move count which otherwise
+// default to 0 and 1.  An error is returned if the record or its
+// position is invalid.
+func ParseEPD(s string) (*Position, map[string]string, error) {
+	match := epdRe.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return nil, nil, fmt.Errorf("chess: epd invalid record %q must have 4 fields", s)
+	}
+	ops, err := parseEPDOperations(match[5])
+	if err != nil {
+		return nil, nil, err
+	}
+	hmvc, fmvn := "0", "1"
+	if v, ok := ops["hmvc"]; ok {
+		hmvc = v
+	}
+	if v, ok := ops["fmvn"]; ok {
+		fmvn = v
+	}
+	fen := strings.Join([]string{match[1], match[2], match[3], match[4], hmvc, fmvn}, " ")
+	pos, err := NewPositionFromFEN(fen)
+	if err != nil {
+		return nil, nil, fmt.Errorf("chess: epd invalid position: %w", err)
+	}
+	return pos, ops, nil
+}
+
+// EPDMoves decodes the moves of an EPD move operand such as the value of
+// a "bm" or "am" operation for the position.  Moves are separated by
+// spaces and are normally in algebraic notation.  An error is returned if
+// any move can't be decoded or isn't valid for the position.
+func EPDMoves(pos *Position, s string) ([]*Move, error) {
+	decoder := multiDecoder([]Decoder{AlgebraicNotation{}, LongAlgebraicNotation{}, UCINotation{}})
+	moves := []*Move{}
+	for _, str := range strings.Fields(s) {
+		m, err := decoder.Decode(pos, str)
+		if err != nil {
+			return nil, err
+		}
+		valid := moveSlice(pos.ValidMoves()).find(m)
+		if valid == nil {
+			return nil, fmt.Errorf("chess: epd move %s is not valid for position %s", str, pos)
+		}
+		moves = append(moves, valid)
+	}
+	return moves, nil
+}
+
+// EPD returns the position in EPD notation without any operations.  It
+// is the position's FEN without the half move clock and move count.
+func (pos *Position) EPD() string {
+	sq := "-"
+	if pos.enPassantSquare != NoSquare {
+		sq = pos.enPassantSquare.String()
+	}
+	board := pos.board.String()
+	if pos.variant == Crazyhouse {
+		board = pos.crazyhouseBoardFEN()
+	}
+	return fmt.Sprintf("%s %s %s %s", board, pos.turn.String(), pos.castleRightsFEN(), sq)
+}
+
+// parseEPDOperations decodes the semicolon terminated operations of an
+// EPD record.  The last operation's semicolon may be omitted.
+func parseEPDOperations(s string) (map[string]string, error) {
+	ops := map[string]string{}
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		end, inQuote := -1, false
+		for i, r := range s {
+			if r == '"' {
+				inQuote = !inQuote
+			} else if r == ';' && !inQuote {
+				end = i
+				break
+			}
+		}
+		if inQuote {
+			return nil, fmt.Errorf("chess: epd operation %q has an unterminated string", s)
+		}
+		op := s
+		if end == -1 {
+			s = ""
+		} else {
+			op, s = s[:end], s[end+1:]
+		}
+		fields := strings.SplitN(strings.TrimSpace(op), " ", 2)
+		if !epdOpcodeRe.MatchString(fields[0]) {
+			return nil, fmt.Errorf("chess: epd invalid opcode %q", fields[0])
+		}
+		value := ""
+		if len(fields) == 2 {
+			value = strings.TrimSpace(fields[1])
+		}
+		if len(value) >= 2 && strings.Count(value, `"`) == 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		ops[fields[0]] = value
+	}
+	return ops, nil
+}
@@ -0,0 +1,67 @@
+package chess
+
+import "testing"
+
+func TestParseEPD(t *testing.T) {
+	epd := `2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001"; c0 "mate; in 3";`
+	pos, ops, err := ParseEPD(epd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.String() != "2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - 0 1" {
+		t.Fatalf("unexpected position %s", pos)
+	}
+	expected := map[string]string{"bm": "Qg6", "id": "WAC.001", "c0": "mate; in 3"}
+	if len(ops) != len(expected) {
+		t.Fatalf("expected operations %v but got %v", expected, ops)
+	}
+	for k, v := range expected {
+		if ops[k] != v {
+			t.Fatalf("expected operation %s to be %q but got %q", k, v, ops[k])
+		}
+	}
+	moves, err := EPDMoves(pos, ops["bm"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 || moves[0].String() != "g3g6" {
+		t.Fatalf("expected best move g3g6 but got %v", moves)
+	}
+	if pos.EPD() != "2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - -" {
+		t.Fatalf("unexpected epd %s", pos.EPD())
+	}
+}
+
+func TestParseEPDMoveCounters(t *testing.T) {
+	pos, ops, err := ParseEPD("rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 hmvc 0; fmvn 2; am Qh5 Ke2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.String() != "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2" {
+		t.Fatalf("unexpected position %s", pos)
+	}
+	moves, err := EPDMoves(pos, ops["am"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 2 || moves[0].String() != "d1h5" || moves[1].String() != "e1e2" {
+		t.Fatalf("expected avoid moves d1h5 and e1e2 but got %v", moves)
+	}
+	if _, err := EPDMoves(pos, "Qh6"); err == nil {
+		t.Fatal("expected an error for an invalid move")
+	}
+}
+
+func TestParseEPDInvalid(t *testing.T) {
+	for _, epd := range []string{
+		"8/8/8/8/8/8/8/8 w - -",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq",
+		`rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - id "open;`,
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 1bm e4;",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - hmvc x;",
+	} {
+		if _, _, err := ParseEPD(epd); err == nil {
+			t.Fatalf("expected an error for epd %s", epd)
+		}
+	}
+}
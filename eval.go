@@ -0,0 +1,81 @@
+package chess
+
+import "math/bits"
+
+// phaseWeights are the weights of the non-pawn pieces used by GamePhase.
+// The starting position has a total weight of maxPhase.
+var phaseWeights = map[PieceType]int{
+	Knight: 1,
+	Bishop: 1,
+	Rook:   2,
+	Queen:  4,
+}
+
+const maxPhase = 24
+
+// MaterialBalance returns white's material minus black's material in
+// centipawns using the conventional piece values of 100 for a pawn, 300
+// for a knight or bishop, 500 for a rook and 900 for a queen.  A positive
+// value means white is ahead.  Kings aren't counted.
+func (pos *Position) MaterialBalance() int {
+	balance := 0
+	for _, pt := range []PieceType{Queen, Rook, Bishop, Knight, Pawn} {
+		n := bits.OnesCount64(uint64(pos.board.bbForPiece(NewPiece(pt, White))))
+		n -= bits.OnesCount64(uint64(pos.board.bbForPiece(NewPiece(pt, Black))))
+		balance += n * pieceValues[pt]
+	}
+	return balance
+}
+
+// GamePhase returns how far the position is from the endgame based on
+// the non-pawn material remaining, from 1 with all of the starting
+// material to 0 when only kings and pawns are left.  Knights and bishops
+// count one, rooks two and queens four, and the phase is capped at 1 when
+// promotions add more material than the starting position has.
+func (pos *Position) GamePhase() float64 {
+	phase := 0
+	for pt, w := range phaseWeights {
+		bb := pos.board.bbForPiece(NewPiece(pt, White)) | pos.board.bbForPiece(NewPiece(pt, Black))
+		phase += bits.OnesCount64(uint64(bb)) * w
+	}
+	if phase > maxPhase {
+		phase = maxPhase
+	}
+	return float64(phase) / maxPhase
+}
+
+// Mobility returns the number of squares each of the given color's
+// pieces can legally move to keyed by the piece's square.  Pinned
+// pieces only count the squares along the pin and pieces that can't
+// move have a mobility of zero.  The moves of the color that isn't to
+// move are counted as if it were its turn without an en passant capture.
+// Promotions to different piece types count as a single square.
+func (pos *Position) Mobility(c Color) map[Square]int {
+	mobility := map[Square]int{}
+	if c != White && c != Black {
+		return mobility
+	}
+	for _, pt := range PieceTypes() {
+		for _, sq := range pos.board.bbForPiece(NewPiece(pt, c)).squares() {
+			mobility[sq] = 0
+		}
+	}
+	p := pos
+	if c != pos.turn {
+		p = pos.copy()
+		p.turn = c
+		p.enPassantSquare = NoSquare
+		p.inCheck = isInCheck(p)
+	}
+	dests := map[Square]bitboard{}
+	p.EachMove(func(m Move) bool {
+		if m.drop == NoPieceType {
+			dests[m.s1] |= bbForSquare(m.s2)
+		}
+		return true
+	})
+	for sq, bb := range dests {
+		mobility[sq] = bits.OnesCount64(uint64(bb))
+	}
+	return mobility
+}
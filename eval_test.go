@@ -0,0 +1,58 @@
+package chess
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMaterialBalance(t *testing.T) {
+	tests := []struct {
+		fen     string
+		balance int
+		phase   float64
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 0, 1},
+		{"rnb1kbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 900, 20.0 / 24},
+		{"4k3/pppp4/8/8/8/8/8/R3K3 w - - 0 1", 100, 2.0 / 24},
+		{"4k3/8/8/8/8/8/PPP5/4K3 w - - 0 1", 300, 0},
+		{"QQQQ1k2/8/8/8/8/8/8/QQQQK3 w - - 0 1", 7200, 1},
+	}
+	for _, test := range tests {
+		pos := unsafeFEN(test.fen)
+		if b := pos.MaterialBalance(); b != test.balance {
+			t.Fatalf("expected %s to have a material balance of %d but got %d", test.fen, test.balance, b)
+		}
+		if p := pos.GamePhase(); p != test.phase {
+			t.Fatalf("expected %s to have a game phase of %f but got %f", test.fen, test.phase, p)
+		}
+	}
+}
+
+func TestMobility(t *testing.T) {
+	tests := []struct {
+		fen      string
+		c        Color
+		mobility map[Square]int
+	}{
+		{"4k3/8/8/8/8/8/8/4K1N1 w - - 0 1", White, map[Square]int{E1: 5, G1: 3}},
+		// black's moves are counted on white's turn
+		{"4k3/8/8/8/8/8/8/4K1N1 w - - 0 1", Black, map[Square]int{E8: 5}},
+		// the pinned knight can't move and the pinned rook stays on the file
+		{"4k3/4r3/8/8/8/8/4N3/4K3 w - - 0 1", White, map[Square]int{E1: 4, E2: 0}},
+		{"4k3/4r3/8/8/8/8/4R3/4K3 w - - 0 1", White, map[Square]int{E1: 4, E2: 5}},
+		// promotions count once
+		{"7k/P7/8/8/8/8/8/K7 w - - 0 1", White, map[Square]int{A1: 3, A7: 1}},
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0 1", NoColor, map[Square]int{}},
+	}
+	for _, test := range tests {
+		pos := unsafeFEN(test.fen)
+		mobility := pos.Mobility(test.c)
+		if !reflect.DeepEqual(mobility, test.mobility) {
+			t.Fatalf("expected mobility %v for %s in %s but got %v", test.mobility, test.c, test.fen, mobility)
+		}
+	}
+	mobility := StartingPosition().Mobility(White)
+	if len(mobility) != 16 || mobility[B1] != 2 || mobility[E2] != 2 || mobility[D1] != 0 {
+		t.Fatalf("expected the starting mobility of white but got %v", mobility)
+	}
+}
@@ -1,11 +1,93 @@
 package chess
 
 import (
+	"errors"
 	"fmt"
+	"math/bits"
 	"strconv"
 	"strings"
 )
 
+// Errors returned by ValidateFEN.  The returned errors wrap one of these
+// so they can be checked with errors.Is.
+var (
+	ErrFENSections      = errors.New("chess: fen must have 6 sections")
+	ErrFENRanks         = errors.New("chess: fen board must have 8 ranks")
+	ErrFENRankLength    = errors.New("chess: fen rank must have 8 squares")
+	ErrFENCharacter     = errors.New("chess: fen board has an illegal character")
+	ErrFENKings         = errors.New("chess: fen board must have one king per side")
+	ErrFENPawnRank      = errors.New("chess: fen board has a pawn on the first or eighth rank")
+	ErrFENTurn          = errors.New("chess: fen has an invalid turn")
+	ErrFENCastleRights  = errors.New("chess: fen has invalid castle rights")
+	ErrFENEnPassant     = errors.New("chess: fen has an invalid en passant square")
+	ErrFENHalfMoveClock = errors.New("chess: fen has an invalid half move clock")
+	ErrFENMoveCount     = errors.New("chess: fen has an invalid move count")
+)
+
+// ValidateFEN returns an error describing the first problem found with
+// the FEN or nil if it is valid.  In addition to the format, it checks
+// that each side has exactly one king, that no pawns are on the first or
+// eighth rank, and that the en passant square is consistent with the
+// side to move.  The error wraps one of the ErrFEN errors.
+func ValidateFEN(fen string) error {
+	parts := strings.Split(strings.TrimSpace(fen), " ")
+	if len(parts) != 6 {
+		return fmt.Errorf("%w: found %d in %q", ErrFENSections, len(parts), fen)
+	}
+	boardStr, _, _, _, err := decodeCrazyhouseBoard(parts[0])
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrFENCharacter, err)
+	}
+	rankStrs := strings.Split(boardStr, "/")
+	if len(rankStrs) != 8 {
+		return fmt.Errorf("%w: found %d in %s", ErrFENRanks, len(rankStrs), parts[0])
+	}
+	for i, rankStr := range rankStrs {
+		count := 0
+		for _, r := range rankStr {
+			c := string(r)
+			if _, ok := fenPieceMap[c]; ok {
+				count++
+			} else if r >= '1' && r <= '8' {
+				count += int(r - '0')
+			} else {
+				return fmt.Errorf("%w: %q in rank %d", ErrFENCharacter, c, 8-i)
+			}
+		}
+		if count != 8 {
+			return fmt.Errorf("%w: rank %d %s has %d", ErrFENRankLength, 8-i, rankStr, count)
+		}
+	}
+	b, err := fenBoard(boardStr)
+	if err != nil {
+		return err
+	}
+	if (b.bbWhitePawn|b.bbBlackPawn)&(bbRank1|bbRank8) != 0 {
+		return fmt.Errorf("%w: %s", ErrFENPawnRank, parts[0])
+	}
+	turn, ok := fenTurnMap[parts[1]]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrFENTurn, parts[1])
+	}
+	if _, err := formCastleRights(parts[2]); err != nil {
+		return fmt.Errorf("%w: %s", ErrFENCastleRights, parts[2])
+	}
+	if _, _, err := formChess960CastleRooks(parts[2], b); err != nil {
+		return fmt.Errorf("%w: %s", ErrFENCastleRights, parts[2])
+	}
+	sq, err := formEnPassant(parts[3])
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrFENEnPassant, parts[3])
+	}
+	if n, err := strconv.Atoi(parts[4]); err != nil || n < 0 {
+		return fmt.Errorf("%w: %s", ErrFENHalfMoveClock, parts[4])
+	}
+	if n, err := strconv.Atoi(parts[5]); err != nil || n < 1 {
+		return fmt.Errorf("%w: %s", ErrFENMoveCount, parts[5])
+	}
+	return validateFENPosition(&Position{board: b, turn: turn, enPassantSquare: sq})
+}
+
 // Decodes FEN notation into a GameState.  An error is returned
 // if there is a parsing error.  FEN notation format:
 // rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1
@@ -15,7 +97,11 @@ func decodeFEN(fen string) (*Position, error) {
 	if len(parts) != 6 {
 		return nil, fmt.Errorf("chess: fen invalid notiation %s must have 6 sections", fen)
 	}
-	b, err := fenBoard(parts[0])
+	boardStr, pkts, promoted, crazyhouse, err := decodeCrazyhouseBoard(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	b, err := fenBoard(boardStr)
 	if err != nil {
 		return nil, err
 	}
@@ -27,6 +113,13 @@ func decodeFEN(fen string) (*Position, error) {
 	if err != nil {
 		return nil, err
 	}
+	chess960, castleRooks, err := formChess960CastleRooks(parts[2], b)
+	if err != nil {
+		return nil, err
+	}
+	if chess960 {
+		rights = chess960CastleRights(castleRooks)
+	}
 	sq, err := formEnPassant(parts[3])
 	if err != nil {
 		return nil, err
@@ -39,6 +132,12 @@ func decodeFEN(fen string) (*Position, error) {
 	if err != nil || moveCount < 1 {
 		return nil, fmt.Errorf("chess: fen invalid move count %s", parts[5])
 	}
+	variant := Standard
+	if crazyhouse {
+		variant = Crazyhouse
+	} else if chess960 {
+		variant = Chess960
+	}
 	return &Position{
 		board:           b,
 		turn:            turn,
@@ -46,6 +145,11 @@ func decodeFEN(fen string) (*Position, error) {
 		enPassantSquare: sq,
 		halfMoveClock:   halfMoveClock,
 		moveCount:       moveCount,
+		chess960:        chess960,
+		castleRooks:     castleRooks,
+		variant:         variant,
+		pockets:         pkts,
+		promoted:        promoted,
 	}, nil
 }
 
@@ -102,8 +206,13 @@ func formCastleRights(castleStr string) (CastleRights, error) {
 	}
 	for _, r := range castleStr {
 		c := fmt.Sprintf("%c", r)
-		switch c {
-		case "K", "Q", "k", "q", "-":
+		switch {
+		case strings.Contains("KQkq-", c):
+		case strings.Contains(fileChars, strings.ToLower(c)):
+			// Shredder-FEN rights name the files of the rooks
+			if strings.Count(castleStr, c) > 1 {
+				return "-", fmt.Errorf("chess: fen invalid castle rights %s", castleStr)
+			}
 		default:
 			return "-", fmt.Errorf("chess: fen invalid castle rights %s", castleStr)
 		}
@@ -111,6 +220,106 @@ func formCastleRights(castleStr string) (CastleRights, error) {
 	return CastleRights(castleStr), nil
 }
 
+// formChess960CastleRooks returns true with the squares of the castling
+// rooks if the castle rights are for a Chess960 position.  Shredder-FEN
+// rights name the files of the castling rooks.  X-FEN rights use KQkq
+// for the outermost rook on each side and are only treated as Chess960
+// when the king isn't on the e file.
+func formChess960CastleRooks(castleStr string, b *Board) (bool, [4]Square, error) {
+	rooks := [4]Square{NoSquare, NoSquare, NoSquare, NoSquare}
+	chess960 := false
+	for _, r := range castleStr {
+		c := fmt.Sprintf("%c", r)
+		color := White
+		if strings.ToLower(c) == c {
+			color = Black
+		}
+		ksq := b.kingSquare(color)
+		if strings.Contains(fileChars, strings.ToLower(c)) {
+			chess960 = true
+		} else if strings.Contains("KQkq", c) && ksq != NoSquare && ksq.File() != FileE && ksq.Rank() == castleRank(color) {
+			chess960 = true
+		}
+	}
+	if !chess960 {
+		return false, rooks, nil
+	}
+	err := fmt.Errorf("chess: fen invalid castle rights %s", castleStr)
+	for _, r := range castleStr {
+		c := fmt.Sprintf("%c", r)
+		if c == "-" {
+			continue
+		}
+		color := White
+		if strings.ToLower(c) == c {
+			color = Black
+		}
+		rank := castleRank(color)
+		rook := NewPiece(Rook, color)
+		ksq := b.kingSquare(color)
+		if ksq == NoSquare || ksq.Rank() != rank {
+			return false, rooks, err
+		}
+		rsq := NoSquare
+		switch strings.ToLower(c) {
+		case "k":
+			for f := FileH; f > ksq.File() && rsq == NoSquare; f-- {
+				if b.Piece(NewSquare(f, rank)) == rook {
+					rsq = NewSquare(f, rank)
+				}
+			}
+		case "q":
+			for f := FileA; f < ksq.File() && rsq == NoSquare; f++ {
+				if b.Piece(NewSquare(f, rank)) == rook {
+					rsq = NewSquare(f, rank)
+				}
+			}
+		default:
+			sq := NewSquare(File(strings.Index(fileChars, strings.ToLower(c))), rank)
+			if b.Piece(sq) == rook && sq.File() != ksq.File() {
+				rsq = sq
+			}
+		}
+		if rsq == NoSquare {
+			return false, rooks, err
+		}
+		side := KingSide
+		if rsq.File() < ksq.File() {
+			side = QueenSide
+		}
+		i := castleIndex(color, side)
+		if rooks[i] != NoSquare {
+			return false, rooks, err
+		}
+		rooks[i] = rsq
+	}
+	return true, rooks, nil
+}
+
+// chess960CastleRights returns the KQkq castle rights for the castling
+// rooks of a Chess960 position.
+func chess960CastleRights(rooks [4]Square) CastleRights {
+	cr := ""
+	for _, c := range []Color{White, Black} {
+		for _, side := range []Side{KingSide, QueenSide} {
+			if rooks[castleIndex(c, side)] != NoSquare {
+				cr += castleRightsChar(c, side)
+			}
+		}
+	}
+	if cr == "" {
+		cr = "-"
+	}
+	return CastleRights(cr)
+}
+
+func castleRank(c Color) Rank {
+	if c == Black {
+		return Rank8
+	}
+	return Rank1
+}
+
 func formEnPassant(enPassant string) (Square, error) {
 	if enPassant == "-" {
 		return NoSquare, nil
@@ -122,6 +331,30 @@ func formEnPassant(enPassant string) (Square, error) {
 	return sq, nil
 }
 
+// validateFENPosition checks rules that decodeFEN doesn't enforce
+// because positions without kings are used in tests and examples.
+func validateFENPosition(pos *Position) error {
+	b := pos.board
+	if bits.OnesCount64(uint64(b.bbWhiteKing)) != 1 || bits.OnesCount64(uint64(b.bbBlackKing)) != 1 {
+		return fmt.Errorf("%w: %s", ErrFENKings, b)
+	}
+	sq := pos.enPassantSquare
+	if sq == NoSquare {
+		return nil
+	}
+	// the pawn that double advanced must sit in front of the en passant
+	// square with both the square and the one it started from empty
+	pawn, from, to := BlackPawn, sq+8, sq-8
+	if pos.turn == Black {
+		pawn, from, to = WhitePawn, sq-8, sq+8
+	}
+	if (pos.turn == White && sq.Rank() != Rank6) || (pos.turn == Black && sq.Rank() != Rank3) ||
+		b.Piece(to) != pawn || b.isOccupied(sq) || b.isOccupied(from) {
+		return fmt.Errorf("%w: %s with %s to move", ErrFENEnPassant, sq, pos.turn.Name())
+	}
+	return nil
+}
+
 var (
 	fenPieceMap = map[string]Piece{
 		"K": WhiteKing,
@@ -1,6 +1,10 @@
 package chess
 
-import "testing"
+import (
+	"errors"
+	"strings"
+	"testing"
+)
 
 var (
 	validFENs = []string{
@@ -52,3 +56,107 @@ func TestInvalidFENs(t *testing.T) {
 		}
 	}
 }
+
+func TestNewPositionFromFEN(t *testing.T) {
+	for _, f := range validFENs {
+		pos, err := NewPositionFromFEN(f)
+		if err != nil {
+			t.Fatal("recieved unexpected error", err)
+		}
+		cp, err := NewPositionFromFEN(pos.FEN())
+		if err != nil {
+			t.Fatal("recieved unexpected error", err)
+		}
+		if f != cp.FEN() {
+			t.Fatalf("fen expected %s but got %s", f, cp.FEN())
+		}
+	}
+}
+
+func TestNewPositionFromInvalidFEN(t *testing.T) {
+	fens := append([]string{
+		// missing king
+		"8/8/8/8/8/8/P7/4K3 w - - 0 1",
+		// two white kings
+		"4k3/8/8/8/8/8/8/3KK3 w - - 0 1",
+		// en passant square for the wrong side
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e3 0 1",
+		// en passant square without a pawn in front
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq e3 0 1",
+		// en passant square with the starting square occupied
+		"rnbqkbnr/pppppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq c6 0 2",
+	}, invalidFENs...)
+	for _, f := range fens {
+		if _, err := NewPositionFromFEN(f); err == nil {
+			t.Fatal("fen expected error from ", f)
+		}
+	}
+}
+
+func TestParseFENLenient(t *testing.T) {
+	tests := []struct {
+		fen      string
+		expected string
+	}{
+		{"7k/8/8/8/8/8/8/R6K w - -", "7k/8/8/8/8/8/8/R6K w - - 0 1"},
+		{"  7k/8/8/8/8/8/8/R6K   b  -  -  3  ", "7k/8/8/8/8/8/8/R6K b - - 3 1"},
+		{"7k/8/8/8/8/8/8/R6K\tw - - - -", "7k/8/8/8/8/8/8/R6K w - - 0 1"},
+		{"7k/8/8/8/8/8/8/R6K w - - 5 0", "7k/8/8/8/8/8/8/R6K w - - 5 1"},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"},
+	}
+	for _, test := range tests {
+		pos, err := ParseFENLenient(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos.String() != test.expected {
+			t.Fatalf("expected fen %s but got %s", test.expected, pos)
+		}
+		if _, err := NewPositionFromFEN(test.fen); err == nil && test.fen != test.expected {
+			t.Fatalf("expected strict parsing of %s to fail", test.fen)
+		}
+	}
+	for _, f := range append([]string{"7k/8/8/8/8/8/8/R6K w -", "7k/8/8/8/8/8/8/R6K w - - x 1"}, invalidFENs...) {
+		// a move count of 0 is accepted
+		if strings.HasSuffix(f, " 0 0") {
+			continue
+		}
+		if _, err := ParseFENLenient(f); err == nil {
+			t.Fatal("fen expected error from ", f)
+		}
+	}
+}
+
+func TestValidateFEN(t *testing.T) {
+	for _, f := range validFENs {
+		if err := ValidateFEN(f); err != nil {
+			t.Fatalf("expected %s to be valid but got %v", f, err)
+		}
+	}
+	tests := []struct {
+		fen string
+		err error
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -", ErrFENSections},
+		{"rnbqkbnr/pppppppp/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", ErrFENRanks},
+		{"rnbqkbnr/pppppppp/8/8/4P2/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", ErrFENRankLength},
+		{"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", ErrFENCharacter},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQXBNR w KQkq - 0 1", ErrFENCharacter},
+		{"rnbq1bnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQ - 0 1", ErrFENKings},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBKKBNR w kq - 0 1", ErrFENKings},
+		{"4k3/8/8/8/8/8/8/P3K3 w - - 0 1", ErrFENPawnRank},
+		{"4k2p/8/8/8/8/8/8/4K3 w - - 0 1", ErrFENPawnRank},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1", ErrFENTurn},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KKkq - 0 1", ErrFENCastleRights},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e4 0 1", ErrFENEnPassant},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e3 0 1", ErrFENEnPassant},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - -1 1", ErrFENHalfMoveClock},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 0", ErrFENMoveCount},
+	}
+	for _, test := range tests {
+		err := ValidateFEN(test.fen)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("expected %s to have error %v but got %v", test.fen, test.err, err)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package chess
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 )
 
 // A Outcome is the result of a game.
@@ -26,6 +28,17 @@ func (o Outcome) String() string {
 	return string(o)
 }
 
+// ParseOutcome returns the outcome for a PGN result token: "1-0", "0-1",
+// "1/2-1/2" or "*" for a game that is ongoing or whose result is unknown.
+// An error is returned for any other string.
+func ParseOutcome(s string) (Outcome, error) {
+	switch o := Outcome(s); o {
+	case NoOutcome, WhiteWon, BlackWon, Draw:
+		return o, nil
+	}
+	return NoOutcome, fmt.Errorf("chess: invalid outcome %q", s)
+}
+
 // A Method is the method that generated the outcome.
 type Method uint8
 
@@ -55,6 +68,23 @@ const (
 	// InsufficientMaterial indicates that the game was automatically drawn
 	// because there was insufficient material for checkmate.
 	InsufficientMaterial
+	// Timeout indicates that the game was won because a player ran out of
+	// time or was drawn because their opponent couldn't checkmate.
+	Timeout
+	// KingInCenter indicates that a King of the Hill game was won by a
+	// king reaching one of the four center squares.
+	KingInCenter
+	// ThirdCheck indicates that a Three-check game was won by giving
+	// check for the third time.
+	ThirdCheck
+	// KnownWin indicates that a position KnownResult looks up is won with
+	// best play by the side with the extra material.  It isn't a method
+	// games end by.
+	KnownWin
+	// KnownDraw indicates that a position KnownResult looks up can't be
+	// won by either side with best play even though there is material to
+	// checkmate.  It isn't a method games end by.
+	KnownDraw
 )
 
 // TagPair represents metadata in a key value pairing used in the PGN format.
@@ -68,12 +98,16 @@ type Game struct {
 	notation             Notation
 	tagPairs             []*TagPair
 	moves                []*Move
+	initialComments      []string
 	comments             [][]string
+	nags                 [][]int
+	variations           [][]*MoveNode
 	positions            []*Position
 	pos                  *Position
 	outcome              Outcome
 	method               Method
 	ignoreAutomaticDraws bool
+	pgnLineWidth         int
 }
 
 // PGN takes a reader and returns a function that updates
@@ -113,6 +147,23 @@ func FEN(fen string) (func(*Game), error) {
 	}, nil
 }
 
+// VariantFEN returns a function that sets the starting position of the
+// game to the FEN played with the variant's rules as read by
+// NewVariantPosition.  The returned function is designed to be used in
+// the NewGame constructor.  An error is returned if the FEN is invalid
+// or the variant isn't supported.
+func VariantFEN(v Variant, fen string) (func(*Game), error) {
+	pos, err := NewVariantPosition(v, fen)
+	if err != nil {
+		return nil, err
+	}
+	return func(g *Game) {
+		g.pos = pos
+		g.positions = []*Position{pos}
+		g.updatePosition()
+	}, nil
+}
+
 // TagPairs returns a function that sets the tag pairs
 // to the given value.  The returned function is designed
 // to be used in the NewGame constructor.
@@ -133,6 +184,17 @@ func UseNotation(n Notation) func(*Game) {
 	}
 }
 
+// PGNLineWidth returns a function that sets the maximum line
+// width of the game's PGN movetext.  Lines are only broken between
+// moves and comments.  A width of zero, the default,
+// puts all of the movetext on a single line.  The returned function
+// is designed to be used in the NewGame constructor.
+func PGNLineWidth(width int) func(*Game) {
+	return func(g *Game) {
+		g.pgnLineWidth = width
+	}
+}
+
 // NewGame defaults to returning a game in the standard
 // opening position.  Options can be given to configure
 // the game's initial state.
@@ -154,6 +216,39 @@ func NewGame(options ...func(*Game)) *Game {
 	return game
 }
 
+// NewGameFromMoves returns a game from the standard opening position
+// with the given moves in UCI notation applied in order, like the UCI
+// "position startpos moves" command.  An error naming the ply is returned
+// if a move can't be decoded or is invalid.
+func NewGameFromMoves(moves []string) (*Game, error) {
+	return newGameFromMoves(NewGame(), moves)
+}
+
+// NewGameFromFENMoves returns a game from the FEN's position with the
+// given moves in UCI notation applied in order, like the UCI "position
+// fen moves" command.  An error is returned if the FEN is invalid or if a
+// move can't be decoded or is invalid.
+func NewGameFromFENMoves(fen string, moves []string) (*Game, error) {
+	opt, err := FEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	return newGameFromMoves(NewGame(opt), moves)
+}
+
+func newGameFromMoves(g *Game, moves []string) (*Game, error) {
+	for i, s := range moves {
+		m, err := ParseUCIMove(g.pos, s)
+		if err == nil {
+			err = g.Move(m)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("chess: move %s on ply %d: %v", s, i+1, err)
+		}
+	}
+	return g, nil
+}
+
 // Move updates the game with the given move.  An error is returned
 // if the move is invalid or the game has already been completed.
 func (g *Game) Move(m *Move) error {
@@ -168,6 +263,37 @@ func (g *Game) Move(m *Move) error {
 	return nil
 }
 
+// ErrNoMovesToUndo is returned by Undo when the game has no moves.
+var ErrNoMovesToUndo = errors.New("chess: no moves to undo")
+
+// Undo takes back the last move of the game and restores the previous
+// position along with its castling rights and en passant square.  The
+// move's comments, annotations and variations are removed and the
+// outcome is reset so the game can continue.  ErrNoMovesToUndo is
+// returned if the game has no moves.
+func (g *Game) Undo() error {
+	if len(g.moves) == 0 {
+		return ErrNoMovesToUndo
+	}
+	n := len(g.moves) - 1
+	g.moves = g.moves[:n:n]
+	g.positions = g.positions[: n+1 : n+1]
+	if len(g.comments) > n {
+		g.comments = g.comments[:n:n]
+	}
+	if len(g.nags) > n {
+		g.nags = g.nags[:n:n]
+	}
+	if len(g.variations) > n {
+		g.variations = g.variations[:n:n]
+	}
+	g.pos = g.positions[n]
+	g.outcome = NoOutcome
+	g.method = NoMethod
+	g.updatePosition()
+	return nil
+}
+
 // MoveStr decodes the given string in game's notation
 // and calls the Move function.  An error is returned if
 // the move can't be decoded or the move is invalid.
@@ -195,11 +321,107 @@ func (g *Game) Moves() []*Move {
 	return append([]*Move(nil), g.moves...)
 }
 
+// CapturedPieces returns the white and black pieces that have been
+// captured in the order they were captured.  Captures are found from the
+// Capture and EnPassant tags of the game's moves so a promoted piece is
+// returned as the piece it promoted to.
+func (g *Game) CapturedPieces() (white, black []Piece) {
+	white, black = []Piece{}, []Piece{}
+	for i, m := range g.moves {
+		pos := g.positions[i]
+		captured := NoPiece
+		if m.HasTag(EnPassant) {
+			captured = NewPiece(Pawn, pos.turn.Other())
+		} else if m.HasTag(Capture) {
+			captured = pos.board.Piece(m.s2)
+		}
+		switch captured.Color() {
+		case White:
+			white = append(white, captured)
+		case Black:
+			black = append(black, captured)
+		}
+	}
+	return white, black
+}
+
 // Comments returns the comments for the game indexed by moves.
 func (g *Game) Comments() [][]string {
 	return append([][]string(nil), g.comments...)
 }
 
+// Comment returns the comment for the move at the given ply, starting
+// from one for the first move, or the comment before the first move for
+// ply zero.  Multiple comments are joined with a space and an empty
+// string is returned if there isn't a comment.
+func (g *Game) Comment(ply int) string {
+	if ply == 0 {
+		return strings.Join(g.initialComments, " ")
+	}
+	if ply < 0 || ply > len(g.comments) {
+		return ""
+	}
+	return strings.Join(g.comments[ply-1], " ")
+}
+
+// SetComment replaces the comments for the move at the given ply,
+// starting from one for the first move, or the comments before the first
+// move for ply zero.  An empty text removes the comments.  The game
+// isn't changed if there isn't a move at the ply.
+func (g *Game) SetComment(ply int, text string) {
+	if ply < 0 || ply > len(g.moves) {
+		return
+	}
+	var comments []string
+	if text != "" {
+		comments = []string{text}
+	}
+	if ply == 0 {
+		g.initialComments = comments
+		return
+	}
+	for len(g.comments) < ply {
+		g.comments = append(g.comments, nil)
+	}
+	g.comments[ply-1] = comments
+}
+
+// NAGs returns the numeric annotation glyphs for the game indexed by
+// moves.
+func (g *Game) NAGs() [][]int {
+	return append([][]int(nil), g.nags...)
+}
+
+// nagOnlyMove is the numeric annotation glyph for a singular move, one
+// without reasonable alternatives.
+const nagOnlyMove = 8
+
+// AnnotateForced adds the $8 only move numeric annotation glyph to each
+// move of the game played from a position with exactly one legal move,
+// such as the forced replies of a puzzle, and returns the indexes of the
+// annotated moves in Moves.  Moves that already have the glyph aren't
+// annotated twice.
+func (g *Game) AnnotateForced() []int {
+	forced := []int{}
+	for i := range g.moves {
+		if g.positions[i].NumLegalMoves() != 1 {
+			continue
+		}
+		forced = append(forced, i)
+		for len(g.nags) <= i {
+			g.nags = append(g.nags, nil)
+		}
+		annotated := false
+		for _, nag := range g.nags[i] {
+			annotated = annotated || nag == nagOnlyMove
+		}
+		if !annotated {
+			g.nags[i] = append(g.nags[i], nagOnlyMove)
+		}
+	}
+	return forced
+}
+
 // TagPairs returns the game's tag pairs.
 func (g *Game) TagPairs() []*TagPair {
 	return append([]*TagPair(nil), g.tagPairs...)
@@ -215,16 +437,102 @@ func (g *Game) Outcome() Outcome {
 	return g.outcome
 }
 
-// Method returns the method in which the outcome occurred.
+// Method returns the method in which the outcome occurred.  For games
+// decoded from PGN, which only record the result, it is the method the
+// final position ended the game by or NoMethod if it can't be derived.
 func (g *Game) Method() Method {
 	return g.method
 }
 
+// ErrResultMismatch is wrapped by the errors returned by Verify.
+var ErrResultMismatch = errors.New("chess: result doesn't match the game")
+
+// Verify returns the mismatches between the game's recorded outcome,
+// such as the result token of a decoded PGN, and the game itself.  The
+// outcome must be a win for the side that checkmated and a draw after
+// stalemate or an automatic draw, and the Result tag has to agree with
+// the outcome.  Games without a recorded result, including those
+// recorded as NoOutcome, aren't checked against their final position or
+// Result tag.  An empty slice is returned if nothing mismatches.
+func (g *Game) Verify() []error {
+	errs := []error{}
+	recorded := g.outcome != "" && g.outcome != NoOutcome
+	if tp := g.GetTagPair("Result"); tp != nil && recorded && tp.Value != string(g.outcome) {
+		errs = append(errs, fmt.Errorf("%w: Result tag %s but the game ended %s", ErrResultMismatch, tp.Value, g.outcome))
+	}
+	outcome, method := g.positionOutcome()
+	if recorded && method != NoMethod && g.outcome != outcome {
+		errs = append(errs, fmt.Errorf("%w: result %s but the final position is %s for %s", ErrResultMismatch, g.outcome, method, outcome))
+	}
+	return errs
+}
+
+// positionOutcome returns the outcome and method the rules require for
+// the game's current position without considering claimed draws or
+// whether automatic draws are ignored.  NoOutcome and NoMethod are
+// returned if the game can continue.
+func (g *Game) positionOutcome() (Outcome, Method) {
+	method := g.pos.Status()
+	switch method {
+	case Checkmate:
+		if g.pos.Turn() == White {
+			return BlackWon, method
+		}
+		return WhiteWon, method
+	case Stalemate, InsufficientMaterial, SeventyFiveMoveRule:
+		return Draw, method
+	case NoMethod:
+		if g.numOfRepetitions() >= 5 {
+			return Draw, FivefoldRepetition
+		}
+		return NoOutcome, NoMethod
+	}
+	if g.pos.variantWinner(method) == Black {
+		return BlackWon, method
+	}
+	return WhiteWon, method
+}
+
 // FEN returns the FEN notation of the current position.
 func (g *Game) FEN() string {
 	return g.pos.String()
 }
 
+// FENAtPly returns the FEN notation of the position after the given
+// number of plies so ply zero is the starting position and the number of
+// moves is the current position.  The game keeps every position so no
+// moves are replayed.  An error is returned if ply is out of range.
+func (g *Game) FENAtPly(ply int) (string, error) {
+	if ply < 0 || ply >= len(g.positions) {
+		return "", fmt.Errorf("chess: ply %d is out of range for a game of %d plies", ply, len(g.moves))
+	}
+	return g.positions[ply].String(), nil
+}
+
+// PlyAtFEN returns the first ply, as used by FENAtPly, whose position
+// has the given FEN and true, or false if the position didn't occur in
+// the game or the FEN is invalid.  Positions matching the FEN exactly
+// are preferred and otherwise the first position that is the same
+// ignoring the move counters, as compared by SamePosition, is returned.
+func (g *Game) PlyAtFEN(fen string) (int, bool) {
+	pos, err := decodeFEN(fen)
+	if err != nil {
+		return 0, false
+	}
+	fen = pos.String()
+	for ply, p := range g.positions {
+		if p.String() == fen {
+			return ply, true
+		}
+	}
+	for ply, p := range g.positions {
+		if p.SamePosition(pos) {
+			return ply, true
+		}
+	}
+	return 0, false
+}
+
 // String implements the fmt.Stringer interface and returns
 // the game's PGN.
 func (g *Game) String() string {
@@ -248,9 +556,82 @@ func (g *Game) UnmarshalText(text []byte) error {
 	return nil
 }
 
+var binaryOutcomes = []Outcome{NoOutcome, WhiteWon, BlackWon, Draw}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface and
+// encodes the game far more compactly than its PGN.  The encoding is a
+// byte each for the variant, outcome and method followed by the big
+// endian uint16 length of the starting position's FEN, the FEN and two
+// bytes per move as packed by Move.MarshalBinary without the tags byte.
+// Tag pairs, comments, NAGs and variations aren't encoded.
+func (g *Game) MarshalBinary() (data []byte, err error) {
+	start := g.positions[0]
+	fen := start.String()
+	outcome := -1
+	for i, o := range binaryOutcomes {
+		if o == g.outcome {
+			outcome = i
+		}
+	}
+	if outcome == -1 {
+		return nil, fmt.Errorf("chess: unable to marshal game: invalid outcome %s", g.outcome)
+	}
+	data = make([]byte, 5, 5+len(fen)+2*len(g.moves))
+	data[0], data[1], data[2] = uint8(start.variant), uint8(outcome), uint8(g.method)
+	binary.BigEndian.PutUint16(data[3:], uint16(len(fen)))
+	data = append(data, fen...)
+	for _, m := range g.moves {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, b[:2]...)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface and
+// decodes the data returned by MarshalBinary.  The moves are replayed from
+// the starting position so they are validated and tagged as if they were
+// played on the game.
+func (g *Game) UnmarshalBinary(data []byte) error {
+	if len(data) < 5 {
+		return errors.New("chess: unable to unmarshal game: binary data is too short")
+	}
+	v, outcome, method := Variant(data[0]), int(data[1]), Method(data[2])
+	if outcome >= len(binaryOutcomes) || method > ThirdCheck {
+		return errors.New("chess: unable to unmarshal game: invalid outcome")
+	}
+	n := int(binary.BigEndian.Uint16(data[3:]))
+	if len(data) < 5+n || (len(data)-5-n)%2 != 0 {
+		return errors.New("chess: unable to unmarshal game: incorrect data length")
+	}
+	opt, err := VariantFEN(v, string(data[5:5+n]))
+	if err != nil {
+		return err
+	}
+	game := NewGame(opt)
+	for i := 5 + n; i < len(data); i += 2 {
+		var m Move
+		if err := m.UnmarshalBinary(data[i : i+2]); err != nil {
+			return err
+		}
+		if err := game.Move(&m); err != nil {
+			return fmt.Errorf("chess: unable to unmarshal game: move %d: %w", len(game.moves)+1, err)
+		}
+	}
+	game.outcome = binaryOutcomes[outcome]
+	game.method = method
+	g.copy(game)
+	return nil
+}
+
 // Draw attempts to draw the game by the given method.  If the
 // method is valid, then the game is updated to a draw by that
 // method.  If the method isn't valid then an error is returned.
+// ThreefoldRepetition and FiftyMoveRule are claimable draws while
+// FivefoldRepetition and SeventyFiveMoveRule are applied automatically
+// when a move is made and can't be claimed.
 func (g *Game) Draw(method Method) error {
 	switch method {
 	case ThreefoldRepetition:
@@ -262,6 +643,8 @@ func (g *Game) Draw(method Method) error {
 			return fmt.Errorf("chess: draw by FiftyMoveRule requires the half move clock to be at 100 or greater but is %d", g.pos.halfMoveClock)
 		}
 	case DrawOffer:
+	case FivefoldRepetition, SeventyFiveMoveRule:
+		return fmt.Errorf("chess: draw by %s is automatic and can't be claimed", method.String())
 	default:
 		return fmt.Errorf("chess: unsupported draw method %s", method.String())
 	}
@@ -284,6 +667,26 @@ func (g *Game) Resign(color Color) {
 	g.method = Resignation
 }
 
+// Timeout ends the game because the given color ran out of time.  The
+// opponent wins unless insufficientOpp is true, meaning the opponent
+// doesn't have the material to checkmate by any series of legal moves,
+// in which case the game is drawn as required by the FIDE Laws of Chess.
+// If the game has already been completed then the game is not updated.
+func (g *Game) Timeout(color Color, insufficientOpp bool) {
+	if g.outcome != NoOutcome || color == NoColor {
+		return
+	}
+	switch {
+	case insufficientOpp:
+		g.outcome = Draw
+	case color == White:
+		g.outcome = BlackWon
+	default:
+		g.outcome = WhiteWon
+	}
+	g.method = Timeout
+}
+
 // EligibleDraws returns valid inputs for the Draw() method.
 func (g *Game) EligibleDraws() []Method {
 	draws := []Method{DrawOffer}
@@ -336,6 +739,32 @@ func (g *Game) RemoveTagPair(k string) bool {
 	return found
 }
 
+// GetTag returns the value of the tag pair with the given key and true
+// or false if the game doesn't have the tag pair.
+func (g *Game) GetTag(k string) (string, bool) {
+	if tp := g.GetTagPair(k); tp != nil {
+		return tp.Value, true
+	}
+	return "", false
+}
+
+// SetTag sets the value of the tag pair with the given key, adding it
+// after the game's other tag pairs if it isn't present.
+func (g *Game) SetTag(k, v string) {
+	g.AddTagPair(k, v)
+}
+
+// Tags returns copies of the game's tag pairs in the order they were
+// added.  PGNs are written with the Seven Tag Roster first followed by
+// the other tag pairs in this order.
+func (g *Game) Tags() []TagPair {
+	tags := make([]TagPair, len(g.tagPairs))
+	for i, tp := range g.tagPairs {
+		tags[i] = *tp
+	}
+	return tags
+}
+
 // MoveHistory is a move's result from Game's MoveHistory method.
 // It contains the move itself, any comments, and the pre and post
 // positions.
@@ -355,7 +784,10 @@ func (g *Game) MoveHistory() []*MoveHistory {
 			continue
 		}
 		m := g.moves[i-1]
-		c := g.comments[i-1]
+		var c []string
+		if len(g.comments) >= i {
+			c = g.comments[i-1]
+		}
 		mh := &MoveHistory{
 			PrePosition:  g.positions[i-1],
 			PostPosition: p,
@@ -378,6 +810,12 @@ func (g *Game) updatePosition() {
 		if g.pos.Turn() == White {
 			g.outcome = BlackWon
 		}
+	} else if winner := g.pos.variantWinner(method); winner != NoColor {
+		g.method = method
+		g.outcome = WhiteWon
+		if winner == Black {
+			g.outcome = BlackWon
+		}
 	}
 	if g.outcome != NoOutcome {
 		return
@@ -396,7 +834,7 @@ func (g *Game) updatePosition() {
 	}
 
 	// insufficient material creates automatic draw
-	if !g.ignoreAutomaticDraws && !g.pos.board.hasSufficientMaterial() {
+	if !g.ignoreAutomaticDraws && !g.pos.hasSufficientMaterial() {
 		g.outcome = Draw
 		g.method = InsufficientMaterial
 	}
@@ -409,25 +847,64 @@ func (g *Game) copy(game *Game) {
 	g.pos = game.pos
 	g.outcome = game.outcome
 	g.method = game.method
+	g.initialComments = append([]string(nil), game.initialComments...)
 	g.comments = game.Comments()
+	g.nags = game.NAGs()
+	g.variations = append([][]*MoveNode(nil), game.variations...)
 }
 
+// Clone returns a copy of the game that shares no memory with it.  The
+// tag pairs, moves, positions, comments, NAGs and variations are all
+// copied so the clone and the game can be used from different goroutines.
 func (g *Game) Clone() *Game {
-	return &Game{
-		tagPairs:  g.TagPairs(),
-		notation:  g.notation,
-		moves:     g.Moves(),
-		positions: g.Positions(),
-		pos:       g.pos,
-		outcome:   g.outcome,
-		method:    g.method,
+	cp := &Game{
+		notation:             g.notation,
+		tagPairs:             make([]*TagPair, len(g.tagPairs)),
+		moves:                make([]*Move, len(g.moves)),
+		initialComments:      append([]string(nil), g.initialComments...),
+		comments:             make([][]string, len(g.comments)),
+		nags:                 make([][]int, len(g.nags)),
+		variations:           make([][]*MoveNode, len(g.variations)),
+		positions:            make([]*Position, len(g.positions)),
+		outcome:              g.outcome,
+		method:               g.method,
+		ignoreAutomaticDraws: g.ignoreAutomaticDraws,
+		pgnLineWidth:         g.pgnLineWidth,
+	}
+	for i, pair := range g.tagPairs {
+		cp.tagPairs[i] = &TagPair{Key: pair.Key, Value: pair.Value}
+	}
+	for i, m := range g.moves {
+		mv := *m
+		cp.moves[i] = &mv
+	}
+	for i, c := range g.comments {
+		cp.comments[i] = append([]string(nil), c...)
+	}
+	for i, n := range g.nags {
+		cp.nags[i] = append([]int(nil), n...)
+	}
+	for i, vars := range g.variations {
+		for _, n := range vars {
+			cp.variations[i] = append(cp.variations[i], n.clone(nil))
+		}
+	}
+	for i, pos := range g.positions {
+		cp.positions[i] = pos.copy()
+		if pos == g.pos {
+			cp.pos = cp.positions[i]
+		}
+	}
+	if cp.pos == nil {
+		cp.pos = g.pos.copy()
 	}
+	return cp
 }
 
 func (g *Game) numOfRepetitions() int {
 	count := 0
 	for _, pos := range g.Positions() {
-		if g.pos.samePosition(pos) {
+		if g.pos.SamePosition(pos) {
 			count++
 		}
 	}
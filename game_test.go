@@ -2,6 +2,7 @@ package chess
 
 import (
 	"log"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -125,6 +126,39 @@ func TestInvalidThreeFoldRepetition(t *testing.T) {
 	}
 }
 
+func TestThreeFoldRepetitionIgnoresUnavailableEnPassant(t *testing.T) {
+	g := NewGame()
+	moves := []string{
+		"e4", "Nf6", "Nf3", "Ng8", "Ng1",
+		"Nf6", "Nf3", "Ng8", "Ng1",
+	}
+	for _, m := range moves {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Draw(ThreefoldRepetition); err != nil {
+		t.Fatalf("%s - %d reps", err.Error(), g.numOfRepetitions())
+	}
+}
+
+func TestThreeFoldRepetitionWithAvailableEnPassant(t *testing.T) {
+	fen, _ := FEN("4k3/8/8/8/3p4/8/4P3/4K3 w - - 0 1")
+	g := NewGame(fen)
+	moves := []string{
+		"e4", "Kd7", "Kd1", "Ke8", "Ke1",
+		"Kd7", "Kd1", "Ke8", "Ke1",
+	}
+	for _, m := range moves {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Draw(ThreefoldRepetition); err == nil {
+		t.Fatal("should not count the position with en passant available as a repetition")
+	}
+}
+
 func TestFiveFoldRepetition(t *testing.T) {
 	g := NewGame()
 	moves := []string{
@@ -170,6 +204,80 @@ func TestSeventyFiveMoveRule(t *testing.T) {
 	}
 }
 
+func TestAutomaticDrawsCantBeClaimed(t *testing.T) {
+	fen, _ := FEN("2r3k1/1q1nbppp/r3p3/3pP3/pPpP4/P1Q2N2/2RN1PPP/2R4K b - b3 149 80")
+	g := NewGame(fen)
+	for _, method := range []Method{FivefoldRepetition, SeventyFiveMoveRule} {
+		if err := g.Draw(method); err == nil {
+			t.Fatalf("expected draw by %s to be automatic", method)
+		}
+	}
+	if err := g.Draw(FiftyMoveRule); err != nil {
+		t.Fatal(err)
+	}
+	if g.Outcome() != Draw || g.Method() != FiftyMoveRule {
+		t.Fatalf("expected a claimed draw by %s but got %s by %s", FiftyMoveRule, g.Outcome(), g.Method())
+	}
+}
+
+func TestStatusSeventyFiveMoveRule(t *testing.T) {
+	tests := []struct {
+		fen    string
+		method Method
+	}{
+		{"2r3k1/1q1nbppp/r3p3/3pP3/pPpP4/P1Q2N2/2RN1PPP/2R4K b - - 149 80", NoMethod},
+		{"2r3k1/1q1nbppp/r3p3/3pP3/pPpP4/P1Q2N2/2RN1PPP/2R4K b - - 150 80", SeventyFiveMoveRule},
+		{"7k/6Q1/6K1/8/8/8/8/8 b - - 150 80", Checkmate},
+	}
+	for _, test := range tests {
+		pos, err := NewPositionFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos.Status() != test.method {
+			t.Fatalf("expected %s to have status %s but got %s", test.fen, test.method, pos.Status())
+		}
+	}
+}
+
+func TestResign(t *testing.T) {
+	g := NewGame()
+	g.Resign(White)
+	if g.Outcome() != BlackWon || g.Method() != Resignation {
+		t.Fatalf("expected %s by %s but got %s by %s", BlackWon, Resignation, g.Outcome(), g.Method())
+	}
+	g.Resign(Black)
+	if g.Outcome() != BlackWon {
+		t.Fatalf("expected a completed game to be unchanged but got %s", g.Outcome())
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		color           Color
+		insufficientOpp bool
+		outcome         Outcome
+	}{
+		{White, false, BlackWon},
+		{Black, false, WhiteWon},
+		{Black, true, Draw},
+		{NoColor, false, NoOutcome},
+	}
+	for _, test := range tests {
+		g := NewGame()
+		g.Timeout(test.color, test.insufficientOpp)
+		if g.Outcome() != test.outcome {
+			t.Fatalf("expected timeout of %s to have outcome %s but got %s", test.color, test.outcome, g.Outcome())
+		}
+		if test.outcome != NoOutcome && g.Method() != Timeout {
+			t.Fatalf("expected method %s but got %s", Timeout, g.Method())
+		}
+	}
+	if Timeout.String() != "Timeout" {
+		t.Fatalf("expected method string Timeout but got %s", Timeout.String())
+	}
+}
+
 func TestInsufficientMaterial(t *testing.T) {
 	fens := []string{
 		"8/2k5/8/8/8/3K4/8/8 w - - 1 1",
@@ -199,6 +307,10 @@ func TestSufficientMaterial(t *testing.T) {
 		"8/2k5/8/8/4P3/3K4/8/8 w - - 1 1",
 		"8/2k5/8/8/8/3KQ3/8/8 w - - 1 1",
 		"8/2k5/8/8/8/3KR3/8/8 w - - 1 1",
+		// knights on both sides can still (unlikely) mate
+		"8/2kn4/8/8/8/3K1N2/8/8 w - - 1 1",
+		"8/2kb4/8/8/8/3K1N2/8/8 w - - 1 1",
+		"8/2k5/8/8/8/3KNN2/8/8 w - - 1 1",
 	}
 	for _, f := range fens {
 		fen, err := FEN(f)
@@ -227,6 +339,85 @@ func TestSerializationCycle(t *testing.T) {
 	}
 }
 
+func TestGameBinary(t *testing.T) {
+	promo, err := FEN("8/1P4k1/8/8/8/8/6K1/8 w - - 0 40")
+	if err != nil {
+		t.Fatal(err)
+	}
+	house, err := VariantFEN(Crazyhouse, "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R[] b KQkq - 1 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		options []func(*Game)
+		moves   []string
+		resign  Color
+	}{
+		{nil, nil, NoColor},
+		{nil, []string{"f3", "e5", "g4", "Qh4#"}, NoColor},
+		{nil, []string{"e4", "e5", "Nf3", "Nc6", "Bb5", "a6", "O-O"}, White},
+		{[]func(*Game){promo}, []string{"b8=N", "Kf6"}, NoColor},
+		{[]func(*Game){house}, []string{"d5", "exd5", "Qxd5", "Nc3", "P@e4"}, NoColor},
+	}
+	for _, test := range tests {
+		g := NewGame(test.options...)
+		for _, m := range test.moves {
+			if err := g.MoveStr(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		g.Resign(test.resign)
+		b, err := g.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) != 5+len(g.positions[0].String())+2*len(test.moves) {
+			t.Fatalf("expected two bytes per move but got %d bytes", len(b))
+		}
+		cp := NewGame()
+		if err := cp.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if cp.String() != g.String() || cp.FEN() != g.FEN() {
+			t.Fatalf("expected %s but got %s", g, cp)
+		}
+		if cp.Outcome() != g.Outcome() || cp.Method() != g.Method() || cp.Position().Variant() != g.Position().Variant() {
+			t.Fatalf("expected %s by %s but got %s by %s", g.Outcome(), g.Method(), cp.Outcome(), cp.Method())
+		}
+		moves, cpMoves := g.Moves(), cp.Moves()
+		for i := range moves {
+			if *moves[i] != *cpMoves[i] {
+				t.Fatalf("expected move %s with tags %s but got %s with tags %s", moves[i], moves[i].tags, cpMoves[i], cpMoves[i].tags)
+			}
+		}
+		// the decoded game can be played on
+		if moves := cp.ValidMoves(); len(moves) > 0 {
+			if err := cp.Move(moves[0]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	g := NewGame()
+	if err := g.MoveStr("e4"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := g.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := [][]byte{
+		b[:4],
+		b[:len(b)-1],
+		append(append([]byte(nil), b[:len(b)-2]...), 0x31, 0xc0, 0x31, 0xc0),
+		append([]byte{0, 4}, b[2:]...),
+	}
+	for _, data := range invalid {
+		if err := NewGame().UnmarshalBinary(data); err == nil {
+			t.Fatalf("expected an error unmarshaling %x", data)
+		}
+	}
+}
+
 func TestInitialNumOfValidMoves(t *testing.T) {
 	g := NewGame()
 	if len(g.ValidMoves()) != 20 {
@@ -251,6 +442,160 @@ func TestTagPairs(t *testing.T) {
 	}
 }
 
+func TestParseOutcome(t *testing.T) {
+	for _, o := range []Outcome{NoOutcome, WhiteWon, BlackWon, Draw} {
+		parsed, err := ParseOutcome(o.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed != o {
+			t.Fatalf("expected %s but got %s", o, parsed)
+		}
+	}
+	for _, s := range []string{"", "1-1", "1/2", "0-1 ", "draw"} {
+		if _, err := ParseOutcome(s); err == nil {
+			t.Fatalf("expected an error parsing %q", s)
+		}
+	}
+}
+
+func TestFENAtPly(t *testing.T) {
+	g := NewGame()
+	for _, m := range []string{"e4", "e5", "Nf3"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+		"rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2",
+		"rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2",
+	}
+	for ply, fen := range expected {
+		s, err := g.FENAtPly(ply)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != fen {
+			t.Fatalf("expected %s at ply %d but got %s", fen, ply, s)
+		}
+	}
+	for _, ply := range []int{-1, 4} {
+		if _, err := g.FENAtPly(ply); err == nil {
+			t.Fatalf("expected an error for ply %d", ply)
+		}
+	}
+}
+
+func TestAnnotateForced(t *testing.T) {
+	// the rook's check leaves Kg2 as white's only move
+	fen, err := FEN("7k/8/8/8/8/8/7P/r6K w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame(fen)
+	for _, m := range []string{"Kg2", "Kg8", "Kf3", "Ra3+", "Ke4"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	forced := g.AnnotateForced()
+	if !reflect.DeepEqual(forced, []int{0}) {
+		t.Fatalf("expected only the first move to be forced but got %v", forced)
+	}
+	if nags := g.NAGs(); len(nags) == 0 || !reflect.DeepEqual(nags[0], []int{nagOnlyMove}) {
+		t.Fatalf("expected the only move glyph on the first move but got %v", nags)
+	}
+	// annotating again doesn't add the glyph twice
+	g.AnnotateForced()
+	if nags := g.NAGs(); !reflect.DeepEqual(nags[0], []int{nagOnlyMove}) {
+		t.Fatalf("expected a single glyph but got %v", nags[0])
+	}
+	if !strings.Contains(g.String(), "1. Kg2 $8 Kg8") {
+		t.Fatalf("expected the glyph in the PGN but got %s", g)
+	}
+}
+
+func TestPlyAtFEN(t *testing.T) {
+	g := NewGame()
+	for _, m := range []string{"Nf3", "Nf6", "Ng1", "Ng8", "e4"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		fen   string
+		ply   int
+		found bool
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 0, true},
+		{"rnbqkb1r/pppppppp/5n2/8/8/5N2/PPPPPPPP/RNBQKB1R w KQkq - 2 2", 2, true},
+		// the repeated starting position matches exactly
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 4 3", 4, true},
+		// the move counters can differ
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 12", 5, true},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1", 0, false},
+		{"not a fen", 0, false},
+	}
+	for _, test := range tests {
+		ply, found := g.PlyAtFEN(test.fen)
+		if ply != test.ply || found != test.found {
+			t.Fatalf("expected ply %d and %t for %s but got %d and %t", test.ply, test.found, test.fen, ply, found)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	pgn := `[Event "Casual Game"]
+[Site "?"]
+[WhiteElo "2100"]
+[TimeControl "300+3"]
+[Result "*"]
+
+1. e4 *`
+	g := NewGame()
+	if err := g.UnmarshalText([]byte(pgn)); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := g.GetTag("TimeControl"); !ok || v != "300+3" {
+		t.Fatalf("expected TimeControl 300+3 but got %s", v)
+	}
+	if _, ok := g.GetTag("BlackElo"); ok {
+		t.Fatal("expected no BlackElo tag")
+	}
+	g.SetTag("Site", "Berlin")
+	g.SetTag("BlackElo", "2050")
+	expected := []TagPair{
+		{"Event", "Casual Game"}, {"Site", "Berlin"}, {"WhiteElo", "2100"},
+		{"TimeControl", "300+3"}, {"Result", "*"}, {"BlackElo", "2050"},
+	}
+	tags := g.Tags()
+	if !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("expected tags %v but got %v", expected, tags)
+	}
+	// the returned tags are copies
+	tags[0].Value = "Changed"
+	if v, _ := g.GetTag("Event"); v != "Casual Game" {
+		t.Fatalf("expected Event to be unchanged but got %s", v)
+	}
+	// unknown tags are written after the seven tag roster
+	out := g.String()
+	if !strings.HasPrefix(out, `[Event "Casual Game"]
+[Site "Berlin"]
+[Date "????.??.??"]
+[Round "?"]
+[White "?"]
+[Black "?"]
+[Result "*"]
+[WhiteElo "2100"]
+[TimeControl "300+3"]
+[BlackElo "2050"]
+`) {
+		t.Fatalf("expected the seven tag roster followed by the other tags but got %s", out)
+	}
+}
+
 func TestPositionHash(t *testing.T) {
 	g1 := NewGame()
 	for _, s := range []string{"Nc3", "e5", "Nf3"} {
@@ -280,6 +625,141 @@ func TestMoveHistory(t *testing.T) {
 	}
 }
 
+func TestUndo(t *testing.T) {
+	g := NewGame()
+	if err := g.Undo(); err != ErrNoMovesToUndo {
+		t.Fatalf("expected error %v but got %v", ErrNoMovesToUndo, err)
+	}
+	for _, m := range []string{"e4", "d5", "e5", "f5"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := g.Position().String()
+	if err := g.MoveStr("exf6"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if g.Position().String() != before {
+		t.Fatalf("expected position %s after undo but got %s", before, g.Position())
+	}
+	if len(g.Moves()) != 4 || len(g.Positions()) != 5 {
+		t.Fatalf("expected 4 moves and 5 positions but got %d and %d", len(g.Moves()), len(g.Positions()))
+	}
+	if err := g.MoveStr("exf6"); err != nil {
+		t.Fatalf("expected en passant to be possible after undo: %v", err)
+	}
+	if !strings.Contains(g.String(), "3. exf6") {
+		t.Fatalf("expected movetext to contain the replayed move but got %s", g.String())
+	}
+}
+
+func TestUndoCheckmate(t *testing.T) {
+	g := NewGame()
+	for _, m := range []string{"f3", "e5", "g4", "Qh4#"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if g.Outcome() != NoOutcome || g.Method() != NoMethod {
+		t.Fatalf("expected undo to reset the outcome but got %s by %s", g.Outcome(), g.Method())
+	}
+	if strings.Contains(g.String(), "Qh4") {
+		t.Fatalf("expected the undone move to be removed from the movetext but got %s", g.String())
+	}
+}
+
+func TestCapturedPieces(t *testing.T) {
+	g := NewGame()
+	// white captures en passant, promotes on d8 and the new queen is taken
+	moves := []string{"e4", "a6", "e5", "d5", "exd6", "Nc6", "dxc7", "Nf6", "cxd8=Q+", "Kxd8"}
+	for _, m := range moves {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	white, black := g.CapturedPieces()
+	expectedWhite := []Piece{WhiteQueen}
+	expectedBlack := []Piece{BlackPawn, BlackPawn, BlackQueen}
+	if len(white) != len(expectedWhite) || len(black) != len(expectedBlack) {
+		t.Fatalf("expected captured pieces %v and %v but got %v and %v", expectedWhite, expectedBlack, white, black)
+	}
+	for i := range white {
+		if white[i] != expectedWhite[i] {
+			t.Fatalf("expected captured white pieces %v but got %v", expectedWhite, white)
+		}
+	}
+	for i := range black {
+		if black[i] != expectedBlack[i] {
+			t.Fatalf("expected captured black pieces %v but got %v", expectedBlack, black)
+		}
+	}
+}
+
+func TestNewGameFromMoves(t *testing.T) {
+	g, err := NewGameFromMoves([]string{"e2e4", "e7e5", "g1f3", "b8c6", "f1c4", "g8f6", "e1g1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQ1RK1 b kq - 5 4"
+	if g.FEN() != expected {
+		t.Fatalf("expected fen %s but got %s", expected, g.FEN())
+	}
+	if last := g.Moves()[6]; !last.HasTag(KingSideCastle) {
+		t.Fatalf("expected move %s to have the KingSideCastle tag", last)
+	}
+	_, err = NewGameFromMoves([]string{"e2e4", "e7e5", "e4e5"})
+	if err == nil || !strings.Contains(err.Error(), "ply 3") {
+		t.Fatalf("expected an error for ply 3 but got %v", err)
+	}
+}
+
+func TestNewGameFromFENMoves(t *testing.T) {
+	g, err := NewGameFromFENMoves("7k/P7/8/8/8/8/8/K7 w - - 0 1", []string{"a7a8q", "h8h7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.FEN() != "Q7/7k/8/8/8/8/8/K7 w - - 1 2" {
+		t.Fatalf("expected fen %s but got %s", "Q7/7k/8/8/8/8/8/K7 w - - 1 2", g.FEN())
+	}
+	if _, err := NewGameFromFENMoves("7k/P7/8/8/8/8/8/K7 w - - 0", nil); err == nil {
+		t.Fatal("expected an error for an invalid fen")
+	}
+	if _, err := NewGameFromFENMoves("7k/P7/8/8/8/8/8/K7 w - - 0 1", []string{"a7a8"}); err == nil {
+		t.Fatal("expected an error for a promotion without a piece")
+	}
+}
+
+func TestSetComment(t *testing.T) {
+	g := NewGame()
+	for _, m := range []string{"e4", "e5", "Nf3"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.SetComment(0, "opening trainer")
+	g.SetComment(2, "symmetrical")
+	g.SetComment(4, "no such move")
+	if g.Comment(0) != "opening trainer" || g.Comment(1) != "" || g.Comment(2) != "symmetrical" || g.Comment(4) != "" {
+		t.Fatalf("unexpected comments %q %q %q %q", g.Comment(0), g.Comment(1), g.Comment(2), g.Comment(4))
+	}
+	if h := g.MoveHistory(); len(h[1].Comments) != 1 || h[1].Comments[0] != "symmetrical" || h[2].Comments != nil {
+		t.Fatalf("unexpected move history comments %v and %v", h[1].Comments, h[2].Comments)
+	}
+	if !strings.HasSuffix(g.String(), "{opening trainer} 1. e4 e5 {symmetrical} 2. Nf3 *") {
+		t.Fatalf("expected comments to be exported but got %s", g.String())
+	}
+	g.SetComment(2, "")
+	if g.Comment(2) != "" {
+		t.Fatalf("expected comment to be removed but got %q", g.Comment(2))
+	}
+}
+
 func BenchmarkStalemateStatus(b *testing.B) {
 	fenStr := "k1K5/8/8/8/8/8/8/1Q6 w - - 0 1"
 	fen, err := FEN(fenStr)
@@ -296,6 +776,52 @@ func BenchmarkStalemateStatus(b *testing.B) {
 	}
 }
 
+func TestClone(t *testing.T) {
+	pgn := `[Event "clone"]
+
+{start} 1. e4 $1 {best by test} e5 (1... c5 2. Nf3) 2. Nf3 *`
+	game, err := decodePGN(pgn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := game.String()
+	cp := game.Clone()
+	if cp.String() != expected {
+		t.Fatalf("expected clone pgn %s but got %s", expected, cp.String())
+	}
+	cp.AddTagPair("Event", "changed")
+	cp.SetComment(1, "changed")
+	if err := cp.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.MoveStr("Nc3"); err != nil {
+		t.Fatal(err)
+	}
+	if game.String() != expected {
+		t.Fatalf("expected changing the clone not to change the game but got %s", game.String())
+	}
+	if cp.Position() != cp.Positions()[len(cp.Positions())-1] {
+		t.Fatal("expected clone position to be its last position")
+	}
+	for i, pos := range cp.Positions() {
+		if pos == game.Positions()[i] {
+			t.Fatalf("expected clone position %d to be copied", i)
+		}
+	}
+
+	done := make(chan bool)
+	for i := 0; i < 2; i++ {
+		go func(g *Game) {
+			g.ValidMoves()
+			g.MoveStr("Nc3")
+			g.Undo()
+			done <- true
+		}(game.Clone())
+	}
+	<-done
+	<-done
+}
+
 func BenchmarkInvalidStalemateStatus(b *testing.B) {
 	fenStr := "8/3P4/8/8/8/7k/7p/7K w - - 2 70"
 	fen, err := FEN(fenStr)
@@ -1,8 +1,11 @@
 package chess
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 )
 
 // A MoveTag represents a notable consequence of a move.
@@ -19,16 +22,81 @@ const (
 	EnPassant
 	// Check indicates that the move puts the opposing player in check.
 	Check
+	// Drop indicates that the move drops a piece from the pocket onto the
+	// board in Crazyhouse.
+	Drop
 	// inCheck indicates that the move puts the moving player in check and
 	// is therefore invalid.
 	inCheck
 )
 
-// A Move is the movement of a piece from one square to another.
+var moveTagNames = []struct {
+	tag  MoveTag
+	name string
+}{
+	{KingSideCastle, "KingSideCastle"},
+	{QueenSideCastle, "QueenSideCastle"},
+	{Capture, "Capture"},
+	{EnPassant, "EnPassant"},
+	{Check, "Check"},
+	{Drop, "Drop"},
+}
+
+// String returns the names of the tags that are set joined by a pipe
+// such as "Capture|Check".  An empty string is returned if no tags
+// are set.
+func (t MoveTag) String() string {
+	return strings.Join(t.names(), "|")
+}
+
+// MarshalJSON implements the json.Marshaler interface and encodes
+// the tags as a list of their names.
+func (t MoveTag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.names())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface and decodes
+// a list of tag names.
+func (t *MoveTag) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	tags := MoveTag(0)
+	for _, name := range names {
+		found := false
+		for _, tn := range moveTagNames {
+			if tn.name == name {
+				tags |= tn.tag
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("chess: unable to unmarshal move tag: invalid tag %s", name)
+		}
+	}
+	*t = tags
+	return nil
+}
+
+func (t MoveTag) names() []string {
+	names := []string{}
+	for _, tn := range moveTagNames {
+		if t&tn.tag != 0 {
+			names = append(names, tn.name)
+		}
+	}
+	return names
+}
+
+// A Move is the movement of a piece from one square to another.  In
+// Crazyhouse a move can instead drop a piece from the pocket onto an
+// empty square in which case both of its squares are that square.
 type Move struct {
 	s1    Square
 	s2    Square
 	promo PieceType
+	drop  PieceType
 	tags  MoveTag
 }
 
@@ -41,38 +109,113 @@ func NewMove(s1 Square, s2 Square, promo PieceType, tags MoveTag) Move {
 	}
 }
 
+// NoMove is the zero Move used by value to mean that there is no move.
+// Its squares are both A1 so it is never valid in a position.
+var NoMove = Move{}
+
+// IsZero returns true if the move is NoMove.  Tags aren't compared.
+func (m Move) IsZero() bool {
+	return m.Equal(NoMove)
+}
+
+// PromotionMoves returns the untagged moves promoting a pawn moving from
+// one square to another to a queen, rook, bishop and knight in that
+// order.  They aren't validated against a position but ValidMovesFrom
+// includes a move for each promotion piece type of a valid pawn move to
+// the last rank.
+func PromotionMoves(from, to Square) []*Move {
+	moves := make([]*Move, len(promoPieceTypes))
+	for i, pt := range promoPieceTypes {
+		moves[i] = &Move{s1: from, s2: to, promo: pt}
+	}
+	return moves
+}
+
+// NewDropMove returns a Crazyhouse move dropping a piece of the given
+// type from the pocket of the side to move onto sq.
+func NewDropMove(pt PieceType, sq Square) Move {
+	return Move{s1: sq, s2: sq, drop: pt, tags: Drop}
+}
+
 // String returns a string useful for debugging.  String doesn't return
-// algebraic notation.
-func (m *Move) String() string {
+// algebraic notation.  Drops are written as the piece letter and the
+// square such as "N@f6".
+func (m Move) String() string {
+	if m.drop != NoPieceType {
+		return m.dropString()
+	}
 	return m.s1.String() + m.s2.String() + m.promo.String()
 }
 
+func (m Move) dropString() string {
+	return strings.ToUpper(m.drop.String()) + "@" + m.s2.String()
+}
+
+// UCI returns the move in the UCI long algebraic format used by engines:
+// the origin and destination squares followed by a lowercase promotion
+// letter such as "e2e4" or "e7e8q".  Drops are written with an
+// uppercase piece letter such as "P@e4" and NoMove is written as the
+// UCI null move "0000".
+func (m Move) UCI() string {
+	if m.IsZero() {
+		return "0000"
+	}
+	if m.drop != NoPieceType {
+		return m.dropString()
+	}
+	s := m.s1.String() + m.s2.String()
+	switch m.promo {
+	case Queen:
+		s += "q"
+	case Rook:
+		s += "r"
+	case Bishop:
+		s += "b"
+	case Knight:
+		s += "n"
+	}
+	return s
+}
+
 // S1 returns the origin square of the move.
-func (m *Move) S1() Square {
+func (m Move) S1() Square {
 	return m.s1
 }
 
 // S2 returns the destination square of the move.
-func (m *Move) S2() Square {
+func (m Move) S2() Square {
 	return m.s2
 }
 
 // Promo returns promotion piece type of the move.
-func (m *Move) Promo() PieceType {
+func (m Move) Promo() PieceType {
 	return m.promo
 }
 
+// DropPiece returns the type of the piece dropped by a Crazyhouse drop
+// or NoPieceType if the move isn't a drop.
+func (m Move) DropPiece() PieceType {
+	return m.drop
+}
+
 // HasTag returns true if the move contains the MoveTag given.
-func (m *Move) HasTag(tag MoveTag) bool {
+func (m Move) HasTag(tag MoveTag) bool {
 	return (tag & m.tags) > 0
 }
 
+// Equal returns true if the moves have the same origin square,
+// destination square, promotion piece type and dropped piece type.  Tags
+// aren't compared.
+func (m Move) Equal(other Move) bool {
+	return m.s1 == other.s1 && m.s2 == other.s2 && m.promo == other.promo && m.drop == other.drop
+}
+
 func (m *Move) addTag(tag MoveTag) {
 	m.tags = m.tags | tag
 }
 
 func (m Move) MarshalJSON() ([]byte, error) {
-	return json.Marshal(string(m.s1.String() + m.s2.String() + m.promo.String()))
+	return json.Marshal(m.String())
 }
 
 func (m *Move) UnmarshalJSON(data []byte) error {
@@ -80,10 +223,23 @@ func (m *Move) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &s); err != nil {
 		return err
 	}
+	return m.unmarshalText(s)
+}
+
+func (m *Move) unmarshalText(s string) error {
 	if len(s) < 4 {
 		return errors.New("chess: unable to unmarshal move: incorrect data length")
 	}
 	var ok bool
+	if s[1] == '@' {
+		pt, ok := strToPieceTypeMap[strings.ToLower(s[0:1])]
+		sq, sqOK := strToSquareMap[s[2:]]
+		if !ok || !sqOK || pt == King {
+			return errors.New("chess: unable to unmarshal move: invalid drop")
+		}
+		*m = NewDropMove(pt, sq)
+		return nil
+	}
 	if m.s1, ok = strToSquareMap[s[0:2]]; !ok {
 		return errors.New("chess: unable to unmarshal move: invalid src square")
 	}
@@ -97,18 +253,125 @@ func (m *Move) UnmarshalJSON(data []byte) error {
 	} else {
 		m.promo = NoPieceType
 	}
+	m.drop = NoPieceType
 	m.tags = MoveTag(0)
 	return nil
 }
 
+// moveBinaryTags are the tags stored by the binary encoding of a Move.
+const moveBinaryTags = KingSideCastle | QueenSideCastle | Capture | EnPassant | Check | Drop
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface and
+// packs the move into three bytes.  The first two are a big endian
+// uint16 of the origin square in the top six bits, the destination square
+// in the next six and the promotion or dropped piece type in the next
+// three.  The third byte holds the move's tags.
+func (m Move) MarshalBinary() (data []byte, err error) {
+	if m.s1 < A1 || m.s1 > H8 || m.s2 < A1 || m.s2 > H8 {
+		return nil, errors.New("chess: unable to marshal move: invalid square")
+	}
+	data = make([]byte, 3)
+	binary.BigEndian.PutUint16(data, m.packed())
+	data[2] = uint8(m.tags & moveBinaryTags)
+	return data, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface and
+// parses the three bytes returned by MarshalBinary.  NoMove is encoded as
+// zero bytes.  The tags byte can be left off to decode a move without
+// tags such as one that will be tagged by validating it against a
+// position.
+func (m *Move) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 && len(data) != 3 {
+		return errors.New("chess: unable to unmarshal move: move binary data should consist of 2 or 3 bytes")
+	}
+	mv, err := unpackMove(binary.BigEndian.Uint16(data))
+	if err != nil {
+		return err
+	}
+	if len(data) == 3 {
+		tags := MoveTag(data[2])
+		if tags&^moveBinaryTags != 0 || tags&Drop != mv.tags&Drop {
+			return errors.New("chess: unable to unmarshal move: invalid tags")
+		}
+		mv.tags = tags
+	}
+	*m = mv
+	return nil
+}
+
+// packed returns the squares and promotion or dropped piece type of the
+// move packed into a uint16 as described by MarshalBinary.
+func (m Move) packed() uint16 {
+	pt := m.promo
+	if m.drop != NoPieceType {
+		pt = m.drop
+	}
+	return uint16(m.s1)<<10 | uint16(m.s2)<<4 | uint16(pt)<<1
+}
+
+// unpackMove returns the move packed by Move.packed.  Zero is NoMove and
+// any other move with the same origin and destination square is a drop.
+func unpackMove(v uint16) (Move, error) {
+	if v == 0 {
+		return NoMove, nil
+	}
+	s1, s2, pt := Square(v>>10), Square(v>>4&0x3f), PieceType(v>>1&0x7)
+	if pt > Pawn || pt == King {
+		return Move{}, errors.New("chess: unable to unmarshal move: invalid piece type")
+	}
+	if s1 == s2 {
+		if pt == NoPieceType {
+			return Move{}, errors.New("chess: unable to unmarshal move: invalid drop")
+		}
+		return NewDropMove(pt, s2), nil
+	}
+	if pt == Pawn {
+		return Move{}, errors.New("chess: unable to unmarshal move: invalid promo piece type")
+	}
+	return Move{s1: s1, s2: s2, promo: pt}, nil
+}
+
+// MoveJSONWithTags is a Move that is encoded to JSON as an object
+// with the move's tags such as {"uci":"e7e8q","tags":["Capture","Check"]}.
+// A Move is encoded to JSON as only its UCI text so convert moves to
+// MoveJSONWithTags to preserve their tags.
+type MoveJSONWithTags Move
+
+type moveJSONWithTags struct {
+	UCI  string  `json:"uci"`
+	Tags MoveTag `json:"tags"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (m MoveJSONWithTags) MarshalJSON() ([]byte, error) {
+	mv := Move(m)
+	return json.Marshal(moveJSONWithTags{UCI: mv.String(), Tags: mv.tags})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (m *MoveJSONWithTags) UnmarshalJSON(data []byte) error {
+	var obj moveJSONWithTags
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	var mv Move
+	if err := mv.unmarshalText(obj.UCI); err != nil {
+		return err
+	}
+	mv.tags = obj.Tags
+	*m = MoveJSONWithTags(mv)
+	return nil
+}
+
 type moveSlice []*Move
 
 func (a moveSlice) find(m *Move) *Move {
-	if m == nil {
+	if m == nil || m.IsZero() {
 		return nil
 	}
 	for _, move := range a {
-		if move.String() == m.String() {
+		if move.Equal(*m) {
 			return move
 		}
 	}
@@ -1,7 +1,11 @@
 package chess
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"sync/atomic"
 	"testing"
 )
 
@@ -320,12 +324,542 @@ func countMoves(t *testing.T, originalPosition *Position, positions []*Position,
 	countMoves(t, originalPosition, newPositions, nodesPerDepth[1:], maxDepth)
 }
 
+func TestValidMovesTagsAndOrder(t *testing.T) {
+	pos := unsafeFEN("r3k2r/8/8/3pP3/8/8/8/R3K2R w KQkq d6 0 1")
+	expected := map[string]MoveTag{
+		"a1a8": Capture | Check,
+		"h1h8": Capture | Check,
+		"e5d6": EnPassant,
+		"e5e6": 0,
+		"e1g1": KingSideCastle,
+		"e1c1": QueenSideCastle,
+	}
+	moves := pos.ValidMoves()
+	if len(moves) != 28 {
+		t.Fatalf("expected 28 valid moves but got %d", len(moves))
+	}
+	for _, m := range moves {
+		if m.HasTag(inCheck) {
+			t.Fatalf("expected move %s to not leave the king in check", m)
+		}
+		tags, ok := expected[m.String()]
+		if ok && m.tags != tags {
+			t.Fatalf("expected move %s to have tags %d but got %d", m, tags, m.tags)
+		}
+	}
+	cp := unsafeFEN(pos.String())
+	for i, m := range cp.ValidMoves() {
+		if m.String() != moves[i].String() {
+			t.Fatalf("expected move %d to be %s but got %s", i, moves[i], m)
+		}
+	}
+}
+
+func TestEnPassantTagOnlyOnCapture(t *testing.T) {
+	g := NewGame()
+	for _, s := range []string{"e4", "a6", "e5", "d5"} {
+		if err := g.MoveStr(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, m := range g.Moves() {
+		if m.HasTag(EnPassant) || m.HasTag(Capture) {
+			t.Fatalf("expected push %s to not be tagged as a capture", m)
+		}
+	}
+	for _, m := range g.ValidMoves() {
+		if m.HasTag(EnPassant) != (m.s1 == E5 && m.s2 == D6) {
+			t.Fatalf("expected only exd6 to be en passant but got %s with tags %d", m, m.tags)
+		}
+	}
+	if err := g.MoveStr("exd6"); err != nil {
+		t.Fatal(err)
+	}
+	if _, black := g.CapturedPieces(); len(black) != 1 || black[0] != BlackPawn {
+		t.Fatalf("expected a black pawn to have been captured but got %v", black)
+	}
+	// a push onto the en passant square of an inconsistent position isn't
+	// en passant either
+	pos := unsafeFEN("4k3/8/8/8/8/8/4p3/K7 b - - 0 1")
+	pos.enPassantSquare = E1
+	for _, m := range pos.ValidMoves() {
+		if m.HasTag(EnPassant) {
+			t.Fatalf("expected push %s to not be en passant", m)
+		}
+	}
+}
+
+func TestCastlingLegality(t *testing.T) {
+	tests := []struct {
+		fen       string
+		kingSide  bool
+		queenSide bool
+	}{
+		{"k7/8/8/8/8/8/8/R3K2R w KQ - 0 1", true, true},
+		// the king can't castle out of check
+		{"k3r3/8/8/8/8/8/8/R3K2R w KQ - 0 1", false, false},
+		// or through an attacked square
+		{"k4r2/8/8/8/8/8/8/R3K2R w KQ - 0 1", false, true},
+		{"k2r4/8/8/8/8/8/8/R3K2R w KQ - 0 1", true, false},
+		{"k7/8/8/8/8/4n3/8/R3K2R w KQ - 0 1", false, false},
+		{"k7/8/8/8/8/8/4p3/R3K2R w KQ - 0 1", false, false},
+		// or into check
+		{"k5r1/8/8/8/8/8/8/R3K2R w KQ - 0 1", false, true},
+		{"k1r5/8/8/8/8/8/8/R3K2R w KQ - 0 1", true, false},
+		// but the rook can pass over an attacked square
+		{"kr6/8/8/8/8/8/8/R3K2R w KQ - 0 1", true, true},
+		{"r3k2r/8/8/8/8/8/8/K4R2 b kq - 0 1", false, true},
+		{"r3k2r/8/8/8/8/8/8/KR6 b kq - 0 1", true, true},
+	}
+	for _, test := range tests {
+		pos, err := NewPositionFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kingSide, queenSide := false, false
+		for _, m := range pos.ValidMoves() {
+			kingSide = kingSide || m.HasTag(KingSideCastle)
+			queenSide = queenSide || m.HasTag(QueenSideCastle)
+		}
+		if kingSide != test.kingSide || queenSide != test.queenSide {
+			t.Fatalf("expected king side %t and queen side %t castling for %s but got %t and %t",
+				test.kingSide, test.queenSide, test.fen, kingSide, queenSide)
+		}
+	}
+}
+
+func TestValidMovesFrom(t *testing.T) {
+	pos := unsafeFEN("r3k2r/8/8/3pP3/8/8/8/R3K2R w KQkq d6 0 1")
+	tests := []struct {
+		sq    Square
+		moves []string
+	}{
+		{E5, []string{"e5e6", "e5d6"}},
+		{E1, []string{"e1d1", "e1f1", "e1d2", "e1e2", "e1f2", "e1g1", "e1c1"}},
+		{E4, []string{}},
+		{D5, []string{}},
+	}
+	for _, test := range tests {
+		moves := pos.ValidMovesFrom(test.sq)
+		if len(moves) != len(test.moves) {
+			t.Fatalf("expected %d moves from %s but got %d", len(test.moves), test.sq, len(moves))
+		}
+		for _, s := range test.moves {
+			m := moveSlice(moves).find(&Move{s1: strToSquareMap[s[0:2]], s2: strToSquareMap[s[2:4]]})
+			if m == nil {
+				t.Fatalf("expected move %s from %s", s, test.sq)
+			}
+		}
+	}
+	for _, m := range pos.ValidMovesFrom(E5) {
+		if m.s2 == D6 && !m.HasTag(EnPassant) {
+			t.Fatalf("expected move %s to have the en passant tag", m)
+		}
+	}
+}
+
+func TestPromotionMoves(t *testing.T) {
+	moves := PromotionMoves(B7, A8)
+	expected := []string{"b7a8q", "b7a8r", "b7a8b", "b7a8n"}
+	if len(moves) != len(expected) {
+		t.Fatalf("expected %d promotion moves but got %d", len(expected), len(moves))
+	}
+	for i, m := range moves {
+		if m.UCI() != expected[i] || m.tags != 0 {
+			t.Fatalf("expected move %s but got %s with tags %s", expected[i], m.UCI(), m.tags)
+		}
+	}
+	// each promotion is a valid move of the pawn
+	pos := unsafeFEN("r3k3/1P6/8/8/8/8/8/4K3 w - - 0 1")
+	valid := pos.ValidMovesFrom(B7)
+	if len(valid) != 8 {
+		t.Fatalf("expected 8 moves from b7 but got %d", len(valid))
+	}
+	for _, to := range []Square{A8, B8} {
+		for _, m := range PromotionMoves(B7, to) {
+			if moveSlice(valid).find(m) == nil {
+				t.Fatalf("expected move %s from b7", m)
+			}
+		}
+	}
+}
+
+func TestMoveUCI(t *testing.T) {
+	tests := []struct {
+		m   Move
+		uci string
+	}{
+		{NewMove(E2, E4, NoPieceType, 0), "e2e4"},
+		{NewMove(E7, E8, Queen, 0), "e7e8q"},
+		{NewMove(A2, B1, Knight, Capture), "a2b1n"},
+		{NewMove(E1, G1, NoPieceType, KingSideCastle), "e1g1"},
+	}
+	for _, test := range tests {
+		if s := test.m.UCI(); s != test.uci {
+			t.Fatalf("expected uci %s but got %s", test.uci, s)
+		}
+	}
+}
+
+func TestPromotionIsMandatory(t *testing.T) {
+	for _, fen := range []string{
+		"1r5k/P7/8/8/8/8/8/K7 w - - 0 1",
+		"7k/8/8/8/8/8/p7/1R5K b - - 0 1",
+	} {
+		pos := unsafeFEN(fen)
+		for _, m := range pos.ValidMoves() {
+			p := pos.Board().Piece(m.S1())
+			if p.Type() == Pawn && (m.S2().Rank() == Rank1 || m.S2().Rank() == Rank8) && m.Promo() == NoPieceType {
+				t.Fatalf("expected pawn move %s to the last rank to promote in %s", m, fen)
+			}
+		}
+		s1, s2, promo := A7, A8, Queen
+		if pos.Turn() == Black {
+			s1, s2 = A2, A1
+		}
+		for _, capture := range []Square{s2, B8, B1} {
+			if capture.Rank() != s2.Rank() {
+				continue
+			}
+			m := NewMove(s1, capture, NoPieceType, 0)
+			if pos.IsLegal(&m) {
+				t.Fatalf("expected move %s without a promotion to be illegal in %s", &m, fen)
+			}
+			opt, err := FEN(fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := NewGame(opt).Move(&m); err == nil {
+				t.Fatalf("expected an error moving %s without a promotion in %s", &m, fen)
+			}
+		}
+		m := NewMove(s1, s2, promo, 0)
+		if !pos.IsLegal(&m) {
+			t.Fatalf("expected move %s to be legal in %s", &m, fen)
+		}
+	}
+}
+
+func TestMoveTagString(t *testing.T) {
+	tests := []struct {
+		tags MoveTag
+		str  string
+	}{
+		{0, ""},
+		{Capture, "Capture"},
+		{Capture | Check, "Capture|Check"},
+		{QueenSideCastle | Check, "QueenSideCastle|Check"},
+		{EnPassant | inCheck, "EnPassant"},
+	}
+	for _, test := range tests {
+		if test.tags.String() != test.str {
+			t.Fatalf("expected tags %d to be %q but got %q", test.tags, test.str, test.tags.String())
+		}
+	}
+}
+
+func TestMoveTagJSON(t *testing.T) {
+	tags := KingSideCastle | Check | inCheck
+	b, err := json.Marshal(tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `["KingSideCastle","Check"]` {
+		t.Fatalf("expected tags json %s but got %s", `["KingSideCastle","Check"]`, b)
+	}
+	var decoded MoveTag
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != KingSideCastle|Check {
+		t.Fatalf("expected decoded tags %s but got %s", KingSideCastle|Check, decoded)
+	}
+	if err := json.Unmarshal([]byte(`["Capture","inCheck"]`), &decoded); err == nil {
+		t.Fatal("expected an error for an invalid tag name")
+	}
+}
+
+func TestMoveJSONWithTags(t *testing.T) {
+	pos := unsafeFEN("1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1")
+	m, err := ParseUCIMove(pos, "a7b8q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal([]MoveJSONWithTags{MoveJSONWithTags(*m)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"uci":"a7b8q","tags":["Capture","Check"]}]`
+	if string(b) != expected {
+		t.Fatalf("expected json %s but got %s", expected, b)
+	}
+	var decoded []MoveJSONWithTags
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	mv := Move(decoded[0])
+	if !mv.Equal(*m) || mv.tags != Capture|Check {
+		t.Fatalf("expected decoded move %s with tags %s but got %s with tags %s", m, m.tags, &mv, mv.tags)
+	}
+	// the default encoding is unchanged
+	b, err = json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `"a7b8q"` {
+		t.Fatalf("expected json %s but got %s", `"a7b8q"`, b)
+	}
+}
+
+func TestIsLegal(t *testing.T) {
+	tests := []struct {
+		fen   string
+		move  *Move
+		legal bool
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", &Move{s1: E2, s2: E4}, true},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", &Move{s1: G1, s2: F3}, true},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", &Move{s1: E2, s2: E5}, false},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", &Move{s1: E4, s2: E5}, false},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", &Move{s1: E7, s2: E5}, false},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", &Move{s1: A1, s2: A2}, false},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", &Move{s1: NoSquare, s2: A2}, false},
+		// pinned knight
+		{"4k3/4r3/8/8/8/8/4N3/4K3 w - - 0 1", &Move{s1: E2, s2: C3}, false},
+		// king can't move into check
+		{"4k3/4r3/8/8/8/8/8/3K4 w - - 0 1", &Move{s1: D1, s2: E1}, false},
+		{"r3k2r/8/8/3pP3/8/8/8/R3K2R w KQkq d6 0 1", &Move{s1: E5, s2: D6}, true},
+		{"r3k2r/8/8/3pP3/8/8/8/R3K2R w KQkq d6 0 1", &Move{s1: E1, s2: G1}, true},
+		{"r3k2r/8/8/3pP3/8/8/8/R3K2R w Qkq d6 0 1", &Move{s1: E1, s2: G1}, false},
+		{"7k/P7/8/8/8/8/8/K7 w - - 0 1", &Move{s1: A7, s2: A8, promo: Knight}, true},
+		{"7k/P7/8/8/8/8/8/K7 w - - 0 1", &Move{s1: A7, s2: A8}, false},
+		{"7k/P7/8/8/8/8/8/K7 w - - 0 1", &Move{s1: A7, s2: A8, promo: King}, false},
+		{"7k/8/P7/8/8/8/8/K7 w - - 0 1", &Move{s1: A6, s2: A7, promo: Queen}, false},
+	}
+	for _, test := range tests {
+		pos := unsafeFEN(test.fen)
+		if pos.IsLegal(test.move) != test.legal {
+			t.Fatalf("expected move %s to have legality %t in %s", test.move, test.legal, test.fen)
+		}
+		// the cached valid moves are used once generated
+		pos.ValidMoves()
+		if pos.IsLegal(test.move) != test.legal {
+			t.Fatalf("expected move %s to have legality %t in %s with cached moves", test.move, test.legal, test.fen)
+		}
+	}
+	if StartingPosition().IsLegal(nil) {
+		t.Fatal("expected a nil move to be illegal")
+	}
+	// every pair of squares agrees with the valid moves
+	for _, perf := range perfResults {
+		pos := unsafeFEN(perf.pos.String())
+		valid := moveSlice(perf.pos.ValidMoves())
+		for s1 := A1; s1 <= H8; s1++ {
+			for s2 := A1; s2 <= H8; s2++ {
+				for _, promo := range []PieceType{NoPieceType, Queen, Knight} {
+					m := &Move{s1: s1, s2: s2, promo: promo}
+					if pos.IsLegal(m) != (valid.find(m) != nil) {
+						t.Fatalf("expected legality of %s in %s to match the valid moves", m, pos)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestMoveError(t *testing.T) {
+	const start = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	tests := []struct {
+		fen  string
+		move *Move
+		err  error
+	}{
+		{start, &Move{s1: E2, s2: E4}, nil},
+		{start, &Move{s1: NoSquare, s2: E4}, ErrInvalidSquare},
+		{start, &Move{s1: E3, s2: E4}, ErrNoPieceAtSource},
+		{start, &Move{s1: E7, s2: E5}, ErrWrongColor},
+		{start, &Move{s1: A1, s2: A2}, ErrOwnPieceAtDestination},
+		{start, &Move{s1: G1, s2: G3}, ErrIllegalPieceMove},
+		{start, &Move{s1: E2, s2: E5}, ErrIllegalPieceMove},
+		{start, &Move{s1: E2, s2: D3}, ErrIllegalPieceMove},
+		{start, &Move{s1: A1, s2: A3}, ErrBlockedPath},
+		{start, &Move{s1: C1, s2: F4}, ErrBlockedPath},
+		{start, &Move{s1: E1, s2: G1}, ErrIllegalCastle},
+		{"rnbqkbnr/pppp1ppp/8/8/4p3/4P3/PPPP1PPP/RNBQKBNR w KQkq - 0 2", &Move{s1: E3, s2: E4}, ErrBlockedPath},
+		{"rnbqkbnr/pppp1ppp/8/8/8/4p3/PPPPPPPP/RNBQKBNR w KQkq - 0 2", &Move{s1: E2, s2: E4}, ErrBlockedPath},
+		{"4k3/4r3/8/8/8/8/4N3/4K3 w - - 0 1", &Move{s1: E2, s2: C3}, ErrLeavesKingInCheck},
+		{"4k3/4r3/8/8/8/8/8/3K4 w - - 0 1", &Move{s1: D1, s2: E1}, ErrLeavesKingInCheck},
+		{"k4r2/8/8/8/8/8/8/R3K2R w KQ - 0 1", &Move{s1: E1, s2: G1}, ErrIllegalCastle},
+		{"kr6/8/8/8/8/8/8/R3K2R w KQ - 0 1", &Move{s1: E1, s2: C1}, nil},
+		{"7k/P7/8/8/8/8/8/K7 w - - 0 1", &Move{s1: A7, s2: A8}, ErrInvalidPromotion},
+		{"7k/P7/8/8/8/8/8/K7 w - - 0 1", &Move{s1: A7, s2: A8, promo: King}, ErrInvalidPromotion},
+		{"7k/8/P7/8/8/8/8/K7 w - - 0 1", &Move{s1: A6, s2: A7, promo: Queen}, ErrInvalidPromotion},
+		{start, &Move{s1: E4, s2: E4, drop: Pawn}, ErrIllegalDrop},
+	}
+	for _, test := range tests {
+		err := unsafeFEN(test.fen).MoveError(test.move)
+		if (err == nil) != (test.err == nil) || (test.err != nil && !errors.Is(err, test.err)) {
+			t.Fatalf("expected error %v for move %s in %s but got %v", test.err, test.move, test.fen, err)
+		}
+	}
+	// every pair of squares agrees with IsLegal
+	for _, perf := range perfResults {
+		pos := unsafeFEN(perf.pos.String())
+		for s1 := A1; s1 <= H8; s1++ {
+			for s2 := A1; s2 <= H8; s2++ {
+				for _, promo := range []PieceType{NoPieceType, Queen, Knight} {
+					m := &Move{s1: s1, s2: s2, promo: promo}
+					if (pos.MoveError(m) == nil) != pos.IsLegal(m) {
+						t.Fatalf("expected the error %v for %s in %s to match its legality", pos.MoveError(m), m, pos)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestEachMove(t *testing.T) {
+	for _, perf := range perfResults {
+		pos := unsafeFEN(perf.pos.String())
+		moves := []Move{}
+		pos.EachMove(func(m Move) bool {
+			moves = append(moves, m)
+			return true
+		})
+		valid := perf.pos.ValidMoves()
+		if len(moves) != len(valid) {
+			t.Fatalf("expected %d moves but got %d for %s", len(valid), len(moves), pos)
+		}
+		for i, m := range moves {
+			if !m.Equal(*valid[i]) || m.tags != valid[i].tags {
+				t.Fatalf("expected move %d to be %s but got %s for %s", i, valid[i], &m, pos)
+			}
+		}
+	}
+	count := 0
+	StartingPosition().EachMove(func(m Move) bool {
+		count++
+		return count < 3
+	})
+	if count != 3 {
+		t.Fatalf("expected iteration to stop after 3 moves but got %d", count)
+	}
+}
+
+func TestValidMovesWithTag(t *testing.T) {
+	fens := []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	}
+	for _, fen := range fens {
+		for _, tag := range []MoveTag{Capture, EnPassant, Check, KingSideCastle, QueenSideCastle, Capture | Check} {
+			expected := []*Move{}
+			for _, m := range unsafeFEN(fen).ValidMoves() {
+				if m.HasTag(tag) {
+					expected = append(expected, m)
+				}
+			}
+			cached := unsafeFEN(fen)
+			cached.ValidMoves()
+			for _, moves := range [][]*Move{unsafeFEN(fen).ValidMovesWithTag(tag), cached.ValidMovesWithTag(tag)} {
+				if len(moves) != len(expected) {
+					t.Fatalf("expected %d %s moves but got %d for %s", len(expected), tag, len(moves), fen)
+				}
+				for i, m := range moves {
+					if !m.Equal(*expected[i]) || m.tags != expected[i].tags {
+						t.Fatalf("expected %s move %d to be %s but got %s for %s", tag, i, expected[i], m, fen)
+					}
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkValidMovesWithTagCapture(b *testing.B) {
+	pos := unsafeFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		pos.ValidMovesWithTag(Capture)
+	}
+}
+
+func TestMoveEqual(t *testing.T) {
+	tests := []struct {
+		m1    Move
+		m2    Move
+		equal bool
+	}{
+		{Move{s1: E2, s2: E4}, Move{s1: E2, s2: E4}, true},
+		{Move{s1: E4, s2: D5}, Move{s1: E4, s2: D5, tags: Capture}, true},
+		{Move{s1: E2, s2: E4}, Move{s1: E2, s2: E3}, false},
+		{Move{s1: E2, s2: E4}, Move{s1: D2, s2: E4}, false},
+		{Move{s1: A7, s2: A8, promo: Queen}, Move{s1: A7, s2: A8, promo: Knight}, false},
+		{Move{s1: A7, s2: A8, promo: Queen}, Move{s1: A7, s2: A8}, false},
+	}
+	for _, test := range tests {
+		if test.m1.Equal(test.m2) != test.equal {
+			t.Fatalf("expected %s equal to %s to be %t", &test.m1, &test.m2, test.equal)
+		}
+	}
+}
+
+func TestMoveValueMethods(t *testing.T) {
+	moves := []Move{}
+	unsafeFEN("4k3/P7/8/8/8/8/8/4K3 w - - 0 1").EachMove(func(m Move) bool {
+		moves = append(moves, m)
+		return true
+	})
+	promos := 0
+	for _, m := range moves {
+		if m.S1() == A7 && m.S2() == A8 && m.Promo() != NoPieceType {
+			promos++
+		}
+		if m.Promo() == Queen && (!m.HasTag(Check) || m.String() != "a7a8q" || m.UCI() != "a7a8q") {
+			t.Fatalf("expected a7a8q with check but got %s", m)
+		}
+	}
+	if promos != 4 {
+		t.Fatalf("expected 4 promotions but got %d", promos)
+	}
+	var s fmt.Stringer = Move{s1: E2, s2: E4}
+	if s.String() != "e2e4" {
+		t.Fatalf("expected e2e4 but got %s", s)
+	}
+}
+
+func BenchmarkMoveSliceFind(b *testing.B) {
+	pos := unsafeFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	moves := moveSlice(pos.ValidMoves())
+	m := &Move{s1: E1, s2: C1}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		moves.find(m)
+	}
+}
+
 func BenchmarkValidMoves(b *testing.B) {
 	pos := unsafeFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
 	b.ResetTimer()
 	for n := 0; n < b.N; n++ {
 		pos.ValidMoves()
-		pos.validMoves = nil
+		pos.validMoves = atomic.Value{}
+	}
+}
+
+func BenchmarkEachMove(b *testing.B) {
+	pos := unsafeFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		pos.EachMove(func(m Move) bool { return true })
+	}
+}
+
+func BenchmarkNumLegalMoves(b *testing.B) {
+	pos := unsafeFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		pos.NumLegalMoves()
 	}
 }
 
@@ -342,3 +876,103 @@ func moveIsValid(pos *Position, m *Move, useTags bool) bool {
 	}
 	return false
 }
+
+func TestMoveBinary(t *testing.T) {
+	moves := []Move{
+		NewMove(E2, E4, NoPieceType, 0),
+		NewMove(E1, G1, NoPieceType, KingSideCastle),
+		NewMove(D7, C8, Queen, Capture|Check),
+		NewMove(A2, A1, Knight, 0),
+		NewMove(E5, D6, NoPieceType, Capture|EnPassant),
+		NewDropMove(Pawn, E4),
+	}
+	for _, m := range moves {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) != 3 {
+			t.Fatalf("expected 3 bytes for %s but got %d", m, len(b))
+		}
+		var decoded Move
+		if err := decoded.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.Equal(m) || decoded.tags != m.tags {
+			t.Fatalf("expected %s with tags %s but got %s with tags %s", m, m.tags, decoded, decoded.tags)
+		}
+		// without the tags byte the move is decoded without tags
+		var untagged Move
+		if err := untagged.UnmarshalBinary(b[:2]); err != nil {
+			t.Fatal(err)
+		}
+		if !untagged.Equal(m) || untagged.tags&^Drop != 0 {
+			t.Fatalf("expected untagged %s but got %s with tags %s", m, untagged, untagged.tags)
+		}
+	}
+	// the inCheck tag isn't encoded
+	b, err := NewMove(E1, E2, NoPieceType, inCheck).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b[2] != 0 {
+		t.Fatalf("expected no tags but got %08b", b[2])
+	}
+	if _, err := NewMove(NoSquare, E2, NoPieceType, 0).MarshalBinary(); err == nil {
+		t.Fatal("expected an error marshaling a move without an origin square")
+	}
+	invalid := [][]byte{
+		{},
+		{0x31},
+		{0x31, 0xc0, 0x00, 0x00},
+		// promotion to a king
+		{0xcd, 0xc2},
+		// promotion to a pawn
+		{0xcd, 0xcc},
+		// drop without a piece
+		{0x71, 0xc0},
+		// drop tag on a move that isn't a drop
+		{0x31, 0xc0, uint8(Drop)},
+		// unknown tag
+		{0x31, 0xc0, 0x80},
+	}
+	for _, b := range invalid {
+		var m Move
+		if err := m.UnmarshalBinary(b); err == nil {
+			t.Fatalf("expected an error unmarshaling %x but got %s", b, m)
+		}
+	}
+}
+
+func TestNoMove(t *testing.T) {
+	var m Move
+	if !m.IsZero() || !NoMove.IsZero() || m != NoMove {
+		t.Fatal("expected the zero move to be NoMove")
+	}
+	for _, m := range []Move{NewMove(E2, E4, NoPieceType, 0), NewMove(A1, A2, NoPieceType, 0), NewDropMove(Pawn, A1)} {
+		if m.IsZero() {
+			t.Fatalf("expected %s to not be NoMove", m)
+		}
+	}
+	if NoMove.UCI() != "0000" {
+		t.Fatalf("expected the null move 0000 but got %s", NoMove.UCI())
+	}
+	pos := StartingPosition()
+	if pos.IsLegal(&NoMove) || moveSlice(pos.ValidMoves()).find(&NoMove) != nil {
+		t.Fatal("expected NoMove to never be valid")
+	}
+	if _, err := pos.Move(&NoMove); err == nil {
+		t.Fatal("expected an error playing NoMove")
+	}
+	b, err := NoMove.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := NewMove(E2, E4, NoPieceType, Check)
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != NoMove {
+		t.Fatalf("expected NoMove but got %s", decoded)
+	}
+}
@@ -0,0 +1,31 @@
+package chess
+
+// nagSymbols maps the numeric annotation glyphs with a traditional move
+// suffix to that suffix.
+var nagSymbols = map[int]string{
+	1: "!",
+	2: "?",
+	3: "!!",
+	4: "??",
+	5: "!?",
+	6: "?!",
+}
+
+// NAGSymbol returns the traditional move suffix for the numeric
+// annotation glyph such as "!" for 1 or "?!" for 6.  An empty string is
+// returned if the glyph doesn't have a suffix.
+func NAGSymbol(nag int) string {
+	return nagSymbols[nag]
+}
+
+// NAGFromSymbol returns the numeric annotation glyph for the traditional
+// move suffix such as 1 for "!" or 6 for "?!".  Zero, the null
+// annotation, is returned if the suffix isn't known.
+func NAGFromSymbol(s string) int {
+	for nag, sym := range nagSymbols {
+		if sym == s {
+			return nag
+		}
+	}
+	return 0
+}
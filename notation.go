@@ -41,13 +41,16 @@ func (UCINotation) String() string {
 
 // Encode implements the Encoder interface.
 func (UCINotation) Encode(pos *Position, m *Move) string {
-	return m.S1().String() + m.S2().String() + m.Promo().String()
+	return m.UCI()
 }
 
 // Decode implements the Decoder interface.
 func (UCINotation) Decode(pos *Position, s string) (*Move, error) {
 	l := len(s)
 	err := fmt.Errorf(`chess: failed to decode long algebraic notation text "%s" for position %s`, s, pos)
+	if l == 4 && s[1] == '@' {
+		return decodeDrop(pos, s)
+	}
 	if l < 4 || l > 5 {
 		return nil, err
 	}
@@ -70,7 +73,7 @@ func (UCINotation) Decode(pos *Position, s string) (*Move, error) {
 	if pos == nil {
 		return m, nil
 	}
-	p := pos.Board().Piece(s1)
+	p := pos.board.Piece(s1)
 	if p.Type() == King {
 		if (s1 == E1 && s2 == G1) || (s1 == E8 && s2 == G8) {
 			m.addTag(KingSideCastle)
@@ -82,13 +85,30 @@ func (UCINotation) Decode(pos *Position, s string) (*Move, error) {
 		m.addTag(Capture)
 	}
 	c1 := p.Color()
-	c2 := pos.Board().Piece(s2).Color()
+	c2 := pos.board.Piece(s2).Color()
 	if c2 != NoColor && c1 != c2 {
 		m.addTag(Capture)
 	}
 	return m, nil
 }
 
+// ParseUCIMove decodes the UCI text s and returns the matching valid
+// move for the position.  Unlike UCINotation's Decode, the move returned
+// is taken from the position's valid moves so all of its tags are set.
+// An error is returned if s is malformed or the move isn't valid in the
+// position.
+func ParseUCIMove(pos *Position, s string) (*Move, error) {
+	m, err := UCINotation{}.Decode(pos, s)
+	if err != nil {
+		return nil, err
+	}
+	valid := moveSlice(pos.ValidMoves()).find(m)
+	if valid == nil {
+		return nil, fmt.Errorf(`chess: move "%s" is not valid for position %s`, s, pos)
+	}
+	return valid, nil
+}
+
 // AlgebraicNotation (or Standard Algebraic Notation) is the
 // official chess notation used by FIDE. Examples: e4, e5,
 // O-O (short castling), e8=Q (promotion)
@@ -103,12 +123,15 @@ func (AlgebraicNotation) String() string {
 // Encode implements the Encoder interface.
 func (AlgebraicNotation) Encode(pos *Position, m *Move) string {
 	checkChar := getCheckChar(pos, m)
+	if m.drop != NoPieceType {
+		return m.dropString() + checkChar
+	}
 	if m.HasTag(KingSideCastle) {
 		return "O-O" + checkChar
 	} else if m.HasTag(QueenSideCastle) {
 		return "O-O-O" + checkChar
 	}
-	p := pos.Board().Piece(m.S1())
+	p := pos.board.Piece(m.S1())
 	pChar := charFromPieceType(p.Type())
 	s1Str := formS1(pos, m)
 	capChar := ""
@@ -122,7 +145,7 @@ func (AlgebraicNotation) Encode(pos *Position, m *Move) string {
 	return pChar + s1Str + capChar + m.s2.String() + promoText + checkChar
 }
 
-var pgnRegex = regexp.MustCompile(`^(?:([RNBQKP]?)([abcdefgh]?)(\d?)(x?)([abcdefgh])(\d)(=[QRBN])?|(O-O(?:-O)?))([+#!?]|e\.p\.)*$`)
+var pgnRegex = regexp.MustCompile(`^(?:([RNBQKP]?)([abcdefgh]?)(\d?)(x?)([abcdefgh])(\d)(=[QRBN])?|(O-O(?:-O)?))([+#!?]|\s*e\.p\.)*$`)
 
 func algebraicNotationParts(s string) (string, string, string, string, string, string, string, string, error) {
 	submatches := pgnRegex.FindStringSubmatch(s)
@@ -133,8 +156,33 @@ func algebraicNotationParts(s string) (string, string, string, string, string, s
 	return submatches[1], submatches[2], submatches[3], submatches[4], submatches[5], submatches[6], submatches[7], submatches[8], nil
 }
 
-// Decode implements the Decoder interface.
+var dropRegex = regexp.MustCompile(`^([PNBRQ]?)@([abcdefgh][12345678])[+#!?]*$`)
+
+// decodeDrop decodes a Crazyhouse drop such as "N@f6" or "@e4" for a
+// pawn and returns the matching valid move.
+func decodeDrop(pos *Position, s string) (*Move, error) {
+	match := dropRegex.FindStringSubmatch(s)
+	if match == nil {
+		return nil, fmt.Errorf("chess: could not decode drop %s for position %s", s, pos)
+	}
+	pt := Pawn
+	if match[1] != "" {
+		pt = strToPieceTypeMap[strings.ToLower(match[1])]
+	}
+	m := NewDropMove(pt, strToSquareMap[match[2]])
+	valid := moveSlice(pos.ValidMovesWithTag(Drop)).find(&m)
+	if valid == nil {
+		return nil, fmt.Errorf("chess: drop %s is not valid for position %s", s, pos)
+	}
+	return valid, nil
+}
+
+// Decode implements the Decoder interface.  Crazyhouse drops are
+// decoded from text such as "N@f6".
 func (AlgebraicNotation) Decode(pos *Position, s string) (*Move, error) {
+	if strings.Contains(s, "@") {
+		return decodeDrop(pos, s)
+	}
 	piece, originFile, originRank, capture, file, rank, promotes, castles, err := algebraicNotationParts(s)
 	if err != nil {
 		return nil, fmt.Errorf("chess: %+v for position %s", err, pos.String())
@@ -151,7 +199,10 @@ func (AlgebraicNotation) Decode(pos *Position, s string) (*Move, error) {
 		}
 
 		// Try and remove the disambiguators and see if it parses. Sometimes they
-		// get extraneously added.
+		// get extraneously added but they still have to match the origin.
+		if (originFile != "" && originFile != m.s1.File().String()) || (originRank != "" && originRank != m.s1.Rank().String()) {
+			continue
+		}
 		options := []string{}
 
 		if piece != "" {
@@ -175,13 +226,83 @@ func (AlgebraicNotation) Decode(pos *Position, s string) (*Move, error) {
 			}
 		}
 	}
+	if castles == "" && originFile == "" && originRank == "" {
+		count := 0
+		for _, m := range pos.ValidMoves() {
+			p := pos.board.Piece(m.s1)
+			if charFromPieceType(p.Type()) == piece && m.s2.String() == file+rank &&
+				(promotes == "" || charForPromo(m.promo) == promotes) {
+				count++
+			}
+		}
+		if count > 1 {
+			return nil, fmt.Errorf("chess: ambiguous algebraic notation %s matches %d moves for position %s", s, count, pos.String())
+		}
+	}
 	return nil, fmt.Errorf("chess: could not decode algebraic notation %s for position %s", s, pos.String())
 }
 
+var sanPromoRegex = regexp.MustCompile(`([abcdefgh][18])=?([QRBNqrbn])$`)
+
+// NormalizeSAN returns the algebraic notation text s in its canonical
+// form.  Surrounding and inner whitespace and annotations such as "!?"
+// and "e.p." are removed, "0" and "o" are accepted for "O" in castling
+// with or without dashes, dashes between squares as in "e2-e4" are
+// removed, a missing "=" or lowercase promotion piece is fixed and the
+// words "check" and "mate" and "++" are replaced with "+" and "#".  The
+// result isn't checked against a position or for being a valid move.
+func NormalizeSAN(s string) string {
+	s = strings.TrimSpace(s)
+	suffix := ""
+	for trimmed := true; trimmed; {
+		trimmed = false
+		lower := strings.ToLower(s)
+		for _, end := range []struct{ text, suffix string }{
+			{"!", ""}, {"?", ""}, {"e.p.", ""}, {"mate", "#"}, {"check", "+"}, {"++", "#"}, {"#", "#"}, {"+", "+"},
+		} {
+			if strings.HasSuffix(lower, end.text) {
+				s = strings.TrimSpace(s[:len(s)-len(end.text)])
+				if suffix == "" {
+					suffix = end.suffix
+				}
+				trimmed = true
+				break
+			}
+		}
+	}
+	s = strings.Join(strings.Fields(s), "")
+	castle := strings.NewReplacer("0", "O", "o", "O", "-", "").Replace(s)
+	switch castle {
+	case "OO":
+		return "O-O" + suffix
+	case "OOO":
+		return "O-O-O" + suffix
+	}
+	s = strings.Replace(s, "-", "", -1)
+	s = strings.Replace(s, ":", "x", -1)
+	if match := sanPromoRegex.FindStringSubmatch(s); match != nil {
+		s = s[:len(s)-len(match[0])] + match[1] + "=" + strings.ToUpper(match[2])
+	}
+	return s + suffix
+}
+
+// ParseSANLenient decodes the algebraic notation text s after normalizing
+// it with NormalizeSAN and returns the matching valid move for the
+// position.  Long algebraic notation such as "Ng1f3" or "e2-e4" is also
+// accepted.  An error is returned if the text doesn't match a valid move.
+func ParseSANLenient(pos *Position, s string) (*Move, error) {
+	m, err := AlgebraicNotation{}.Decode(pos, NormalizeSAN(s))
+	if err != nil {
+		return nil, fmt.Errorf(`chess: move "%s" is not valid for position %s: %w`, s, pos, err)
+	}
+	return m, nil
+}
+
 // LongAlgebraicNotation is a fully expanded version of
 // algebraic notation in which the starting and ending
 // squares are specified.
 // Examples: e2e4, Rd3xd7, O-O (short castling), e7e8=Q (promotion)
+// Decoding also accepts dashes between the squares such as Ng1-f3.
 type LongAlgebraicNotation struct{}
 
 // String implements the fmt.Stringer interface and returns
@@ -193,12 +314,15 @@ func (LongAlgebraicNotation) String() string {
 // Encode implements the Encoder interface.
 func (LongAlgebraicNotation) Encode(pos *Position, m *Move) string {
 	checkChar := getCheckChar(pos, m)
+	if m.drop != NoPieceType {
+		return m.dropString() + checkChar
+	}
 	if m.HasTag(KingSideCastle) {
 		return "O-O" + checkChar
 	} else if m.HasTag(QueenSideCastle) {
 		return "O-O-O" + checkChar
 	}
-	p := pos.Board().Piece(m.S1())
+	p := pos.board.Piece(m.S1())
 	pChar := charFromPieceType(p.Type())
 	s1Str := m.s1.String()
 	capChar := ""
@@ -212,9 +336,34 @@ func (LongAlgebraicNotation) Encode(pos *Position, m *Move) string {
 	return pChar + s1Str + capChar + m.s2.String() + promoText + checkChar
 }
 
-// Decode implements the Decoder interface.
+var lanRegex = regexp.MustCompile(`^([KQRBNP]?)([abcdefgh][12345678])[-x:]?([abcdefgh][12345678])=?([QRBNqrbn]?)[+#!?]*$`)
+
+// Decode implements the Decoder interface.  Both the origin and
+// destination squares are required, optionally separated by a dash or
+// capture mark as in "Ng1-f3", "Bf4xd6" or "e7-e8Q", and the piece letter
+// must match the piece on the origin square with no letter for pawns.
+// Castling, drops and other text that isn't long algebraic notation is
+// decoded as algebraic notation.
 func (LongAlgebraicNotation) Decode(pos *Position, s string) (*Move, error) {
-	return AlgebraicNotation{}.Decode(pos, s)
+	match := lanRegex.FindStringSubmatch(s)
+	if match == nil {
+		return AlgebraicNotation{}.Decode(pos, s)
+	}
+	pt := Pawn
+	if match[1] != "" {
+		pt = strToPieceTypeMap[strings.ToLower(match[1])]
+	}
+	s1, s2 := strToSquareMap[match[2]], strToSquareMap[match[3]]
+	if p := pos.board.Piece(s1); p.Type() != pt || p.Color() != pos.turn {
+		return nil, fmt.Errorf("chess: long algebraic notation %s doesn't match the piece on %s for position %s", s, s1, pos)
+	}
+	promo := pieceTypeFromChar(strings.ToLower(match[4]))
+	for _, m := range pos.ValidMoves() {
+		if m.s1 == s1 && m.s2 == s2 && m.promo == promo && m.drop == NoPieceType {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("chess: could not decode long algebraic notation %s for position %s", s, pos)
 }
 
 func getCheckChar(pos *Position, move *Move) string {
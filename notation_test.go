@@ -107,9 +107,70 @@ var (
 			Pos:  unsafeFEN("rnbqkbnr/ppp1pppp/8/3p4/3P4/8/PPP1PPPP/RNBQKBNR w KQkq - 0 2"),
 			Text: "bf4",
 		},
+		{
+			// ambiguous since both rooks can reach e2
+			N:    AlgebraicNotation{},
+			Pos:  unsafeFEN("7k/8/8/4R3/8/8/8/4RK2 w - - 0 1"),
+			Text: "Re2",
+		},
+		{
+			// ambiguous since the promotion piece is missing
+			N:    AlgebraicNotation{},
+			Pos:  unsafeFEN("4k3/P7/8/8/8/8/8/4K3 w - - 0 1"),
+			Text: "a8",
+		},
+		{
+			// the origin square doesn't match the pawn moving to f3
+			N:    AlgebraicNotation{},
+			Pos:  unsafeFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"),
+			Text: "g1f3",
+		},
+		{
+			// the piece on g1 is a knight rather than a bishop
+			N:    LongAlgebraicNotation{},
+			Pos:  unsafeFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"),
+			Text: "Bg1-f3",
+		},
+		{
+			// moves without a piece letter are pawn moves
+			N:    LongAlgebraicNotation{},
+			Pos:  unsafeFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"),
+			Text: "g1-f3",
+		},
+		{
+			// the knight on g8 belongs to the side not to move
+			N:    LongAlgebraicNotation{},
+			Pos:  unsafeFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"),
+			Text: "Ng8-f6",
+		},
 	}
 )
 
+func TestLongAlgebraicNotationDecode(t *testing.T) {
+	tests := []struct {
+		fen  string
+		text string
+		uci  string
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "Ng1-f3", "g1f3"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e2e4", "e2e4"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e4", "e2e4"},
+		{"7k/4P3/8/8/8/8/8/3K4 w - - 0 1", "e7-e8Q", "e7e8q"},
+		{"7k/4P3/8/8/8/8/8/3K4 w - - 0 1", "e7e8=N", "e7e8n"},
+		{"4k3/8/3p4/8/5B2/8/8/4K3 w - - 0 1", "Bf4xd6", "f4d6"},
+		{"r3k3/8/8/8/8/8/8/4K3 b q - 0 1", "O-O-O", "e8c8"},
+	}
+	for _, test := range tests {
+		m, err := LongAlgebraicNotation{}.Decode(unsafeFEN(test.fen), test.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.UCI() != test.uci {
+			t.Fatalf("expected %s to decode as %s but got %s", test.text, test.uci, m.UCI())
+		}
+	}
+}
+
 func TestInvalidDecoding(t *testing.T) {
 	for _, test := range invalidDecodeTests {
 		if _, err := test.N.Decode(test.Pos, test.Text); err == nil {
@@ -117,3 +178,143 @@ func TestInvalidDecoding(t *testing.T) {
 		}
 	}
 }
+
+func TestParseUCIMove(t *testing.T) {
+	tests := []struct {
+		pos  *Position
+		text string
+		tags MoveTag
+	}{
+		{unsafeFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"), "e2e4", 0},
+		{unsafeFEN("r1bqkbnr/ppp1p1pp/2n5/3pPp2/8/5N2/PPPP1PPP/RNBQKB1R w KQkq f6 0 4"), "e5f6", EnPassant},
+		{unsafeFEN("r1bqkbnr/ppp1pppp/2n5/3p4/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3"), "e4d5", Capture},
+		{unsafeFEN("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1"), "e1g1", KingSideCastle},
+		{unsafeFEN("4k3/P7/8/8/8/8/8/4K3 w - - 0 1"), "a7a8q", Check},
+	}
+	for _, test := range tests {
+		m, err := ParseUCIMove(test.pos, test.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.tags != test.tags {
+			t.Fatalf("expected move %s to have tags %d but got %d", test.text, test.tags, m.tags)
+		}
+	}
+}
+
+func TestParseUCIMoveInvalid(t *testing.T) {
+	pos := unsafeFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	for _, text := range []string{"e2e5", "e7e5", "e2", "z2e4", "e2e4x"} {
+		if _, err := ParseUCIMove(pos, text); err == nil {
+			t.Fatalf("expected move %s to be invalid", text)
+		}
+	}
+}
+
+var (
+	algebraicEncodeTests = []struct {
+		pos  *Position
+		uci  string
+		text string
+	}{
+		// only one knight can reach d2 since the other is pinned
+		{unsafeFEN("4k3/8/8/8/8/5N2/8/rN2K3 w - - 0 1"), "f3d2", "Nd2"},
+		// file disambiguation
+		{unsafeFEN("4k3/8/8/8/8/5N2/8/1N2K3 w - - 0 1"), "b1d2", "Nbd2"},
+		// rank disambiguation
+		{unsafeFEN("4k3/8/8/R7/8/8/8/R3K3 w - - 0 1"), "a1a3", "R1a3"},
+		// file and rank disambiguation
+		{unsafeFEN("4k3/8/8/8/8/Q7/8/Q1Q1K3 w - - 0 1"), "a1b2", "Qa1b2"},
+		{unsafeFEN("4k3/8/8/8/8/8/8/R3K2R w KQ - 0 1"), "e1c1", "O-O-O"},
+		{unsafeFEN("4k3/8/8/8/8/8/8/R3K2R w KQ - 0 1"), "e1g1", "O-O"},
+		{unsafeFEN("4k3/P7/8/8/8/8/8/4K3 w - - 0 1"), "a7a8q", "a8=Q+"},
+		{unsafeFEN("r3k3/1P6/8/8/8/8/8/4K3 w - - 0 1"), "b7a8n", "bxa8=N"},
+		{unsafeFEN("rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq - 0 2"), "d8h4", "Qh4#"},
+		{unsafeFEN("r1bqkbnr/ppp1p1pp/2n5/3pPp2/8/5N2/PPPP1PPP/RNBQKB1R w KQkq f6 0 4"), "e5f6", "exf6"},
+	}
+)
+
+func TestNormalizeSAN(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{"e4", "e4"},
+		{"0-0", "O-O"},
+		{"OO", "O-O"},
+		{"o-o-o", "O-O-O"},
+		{"0-0-0+", "O-O-O+"},
+		{"e2-e4", "e2e4"},
+		{"Ng1-f3+", "Ng1f3+"},
+		{"Nf3!?", "Nf3"},
+		{"Qxe7 mate", "Qxe7#"},
+		{"Qxe7+ check", "Qxe7+"},
+		{"Rxa8++", "Rxa8#"},
+		{"  exd6 e.p. ", "exd6"},
+		{"e8q", "e8=Q"},
+		{"dxc1=n#", "dxc1=N#"},
+		{"e5:d6", "e5xd6"},
+	}
+	for _, test := range tests {
+		if s := NormalizeSAN(test.s); s != test.expected {
+			t.Fatalf("expected %q to normalize to %q but got %q", test.s, test.expected, s)
+		}
+	}
+}
+
+func TestParseSANLenient(t *testing.T) {
+	tests := []struct {
+		fen string
+		s   string
+		uci string
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e2-e4", "e2e4"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "Ng1-f3!?", "g1f3"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "0-0", "e1g1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "ooo", "e8c8"},
+		{"7k/P7/8/8/8/8/8/K7 w - - 0 1", "a8q check", "a7a8q"},
+		{"6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", "Ra8 mate", "a1a8"},
+	}
+	for _, test := range tests {
+		pos := unsafeFEN(test.fen)
+		m, err := ParseSANLenient(pos, test.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.String() != test.uci {
+			t.Fatalf("expected %q to decode to %s but got %s", test.s, test.uci, m)
+		}
+	}
+	if _, err := ParseSANLenient(StartingPosition(), "e2-e5"); err == nil {
+		t.Fatal("expected an error for an invalid move")
+	}
+}
+
+func TestAlgebraicNotationEncode(t *testing.T) {
+	for _, test := range algebraicEncodeTests {
+		m, err := ParseUCIMove(test.pos, test.uci)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := AlgebraicNotation{}.Encode(test.pos, m)
+		if text != test.text {
+			t.Fatalf("starting from board\n%s\n expected move %s to encode as %s but got %s", test.pos.board.Draw(), test.uci, test.text, text)
+		}
+	}
+}
+
+func TestAlgebraicNotationRoundTrip(t *testing.T) {
+	for _, perf := range perfResults {
+		pos := perf.pos
+		for _, m := range pos.ValidMoves() {
+			text := AlgebraicNotation{}.Encode(pos, m)
+			decoded, err := AlgebraicNotation{}.Decode(pos, text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.String() != m.String() || decoded.tags != m.tags {
+				t.Fatalf("expected %s to decode to %s but got %s", text, m, decoded)
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package chess
+
+// Perft returns the number of leaf nodes in the move tree of the position
+// searched to the given depth.  The counts can be compared to published
+// results (https://www.chessprogramming.org/Perft_Results) to verify move
+// generation.  A depth of zero or less returns one.
+func Perft(pos *Position, depth int) uint64 {
+	if depth <= 0 {
+		return 1
+	}
+	moves := pos.ValidMoves()
+	if depth == 1 {
+		return uint64(len(moves))
+	}
+	var nodes uint64
+	for _, m := range moves {
+		nodes += Perft(pos.Update(m), depth-1)
+	}
+	return nodes
+}
+
+// PerftDivide returns the Perft node counts for each valid move of the
+// position keyed by the move in UCI notation.  The counts are searched to
+// depth-1 after the move so their sum is equal to Perft(pos, depth).  Dividing
+// the counts is useful for finding the move that a move generation bug is
+// under.  A depth of zero or less returns an empty map.
+func PerftDivide(pos *Position, depth int) map[string]uint64 {
+	counts := map[string]uint64{}
+	if depth <= 0 {
+		return counts
+	}
+	for _, m := range pos.ValidMoves() {
+		counts[m.String()] = Perft(pos.Update(m), depth-1)
+	}
+	return counts
+}
@@ -0,0 +1,50 @@
+package chess
+
+import "testing"
+
+func TestPerft(t *testing.T) {
+	for _, perf := range perfResults {
+		for i, nodes := range perf.nodesPerDepth {
+			depth := i + 1
+			if depth > 3 {
+				break
+			}
+			if got := Perft(perf.pos, depth); got != uint64(nodes) {
+				t.Fatalf("expected perft(%d) of %s to be %d but got %d", depth, perf.pos, nodes, got)
+			}
+		}
+	}
+}
+
+func TestPerftDivide(t *testing.T) {
+	// Kiwipete
+	pos := unsafeFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	counts := PerftDivide(pos, 2)
+	if len(counts) != 48 {
+		t.Fatalf("expected 48 root moves but got %d", len(counts))
+	}
+	var sum uint64
+	for _, n := range counts {
+		sum += n
+	}
+	if sum != 2039 {
+		t.Fatalf("expected divided counts to sum to 2039 but got %d", sum)
+	}
+	expected := map[string]uint64{"e1g1": 43, "e1c1": 43, "d5e6": 46, "e2a6": 36}
+	for m, n := range expected {
+		if counts[m] != n {
+			t.Fatalf("expected %d nodes after %s but got %d", n, m, counts[m])
+		}
+	}
+	if Perft(pos, 0) != 1 || len(PerftDivide(pos, 0)) != 0 {
+		t.Fatal("expected depth zero to count only the root")
+	}
+}
+
+func BenchmarkPerft(b *testing.B) {
+	pos := unsafeFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		Perft(pos, 3)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -64,7 +65,8 @@ func (s *Scanner) Scan() bool {
 		}
 		line := strings.TrimSpace(s.scanr.Text())
 		isTagPair := strings.HasPrefix(line, "[")
-		isMoveSeq := strings.HasPrefix(line, "1. ")
+		// movetext may start with a comment or a black move
+		isMoveText := line != "" && !isTagPair
 		switch state {
 		case notInPGN:
 			if !isTagPair {
@@ -73,7 +75,7 @@ func (s *Scanner) Scan() bool {
 			state = inTagPairs
 			sb.WriteString(line + "\n")
 		case inTagPairs:
-			if isMoveSeq {
+			if isMoveText {
 				state = inMoves
 			}
 			sb.WriteString(line + "\n")
@@ -98,6 +100,154 @@ func (s *Scanner) Err() error {
 	return s.err
 }
 
+// PGNScanner reads chess games one at a time from concatenated PGN
+// files such as https://database.lichess.org/.  Unlike Scanner, game
+// boundaries are found by the game termination marker or the next game's
+// tag pairs rather than by blank lines, so comments can contain blank
+// lines and result-like text.  Only the current game is held in memory.
+type PGNScanner struct {
+	r       *bufio.Reader
+	pending string
+	game    *Game
+	gameErr error
+	err     error
+}
+
+// NewPGNScanner returns a new PGN scanner reading from r.
+func NewPGNScanner(r io.Reader) *PGNScanner {
+	return &PGNScanner{r: bufio.NewReader(r)}
+}
+
+// Scan advances the scanner to the next game, which is then available
+// from Game.  It returns false when the input is exhausted or a read
+// error occurs, which is available from Err.  A game that can't be
+// decoded doesn't stop scanning.
+func (s *PGNScanner) Scan() bool {
+	s.game, s.gameErr = nil, nil
+	if s.err != nil && s.pending == "" {
+		return false
+	}
+	var sb strings.Builder
+	state := notInPGN
+	commentDepth, variationDepth := 0, 0
+	for {
+		line := s.pending
+		s.pending = ""
+		if line == "" {
+			l, err := s.r.ReadString('\n')
+			if err != nil && err != io.EOF {
+				s.err = err
+				return false
+			}
+			s.err = err
+			line = l
+		}
+		trimmed := strings.TrimSpace(line)
+		isTagPair := commentDepth == 0 && strings.HasPrefix(trimmed, "[")
+		switch state {
+		case notInPGN, inTagPairs:
+			if trimmed == "" {
+				break
+			}
+			if isTagPair {
+				state = inTagPairs
+				sb.WriteString(trimmed + "\n")
+				break
+			}
+			state = inMoves
+			s.pending = line
+		case inMoves:
+			if isTagPair {
+				// the next game started without this one being terminated
+				s.pending = line
+				s.decode(sb.String())
+				return true
+			}
+			end := pgnTerminationIndex(line, &commentDepth, &variationDepth)
+			if end < 0 {
+				sb.WriteString(line)
+				break
+			}
+			sb.WriteString(line[:end])
+			if rest := line[end:]; strings.TrimSpace(rest) != "" {
+				s.pending = rest
+			}
+			s.decode(sb.String())
+			return true
+		}
+		if s.err == io.EOF && s.pending == "" {
+			if state == notInPGN {
+				return false
+			}
+			s.decode(sb.String())
+			return true
+		}
+	}
+}
+
+// Game returns the game from the most recent Scan and any error decoding
+// it.
+func (s *PGNScanner) Game() (*Game, error) {
+	return s.game, s.gameErr
+}
+
+// Err returns the first non-EOF read error encountered by the scanner.
+func (s *PGNScanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+func (s *PGNScanner) decode(pgn string) {
+	s.game, s.gameErr = decodePGN(pgn)
+}
+
+// pgnTerminationIndex returns the index just after the game termination
+// marker in the movetext line or -1 if it doesn't have one.  Markers
+// inside comments and variations are skipped and the comment and
+// variation depths are carried across lines.
+func pgnTerminationIndex(line string, commentDepth, variationDepth *int) int {
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if *commentDepth > 0 {
+			if c == '{' {
+				*commentDepth++
+			} else if c == '}' {
+				*commentDepth--
+			}
+			continue
+		}
+		switch c {
+		case '{':
+			*commentDepth++
+			continue
+		case '(':
+			*variationDepth++
+			continue
+		case ')':
+			if *variationDepth > 0 {
+				*variationDepth--
+			}
+			continue
+		}
+		if *variationDepth > 0 || (i > 0 && !isPGNSeparator(line[i-1])) {
+			continue
+		}
+		for _, marker := range []string{"1-0", "0-1", "1/2-1/2", "*"} {
+			end := i + len(marker)
+			if strings.HasPrefix(line[i:], marker) && (end == len(line) || isPGNSeparator(line[end])) {
+				return end
+			}
+		}
+	}
+	return -1
+}
+
+func isPGNSeparator(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == ')' || c == '}'
+}
+
 // GamesFromPGN returns all PGN decoding games from the
 // reader.  It is designed to be used decoding multiple PGNs
 // in the same file.  An error is returned if there is an
@@ -150,57 +300,284 @@ func (a multiDecoder) Decode(pos *Position, s string) (*Move, error) {
 
 func decodePGN(pgn string) (*Game, error) {
 	tagPairs := getTagPairs(pgn)
-	moveComments, outcome := moveListWithComments(pgn)
+	moveComments, comments, outcome := moveListWithComments(pgn)
 	gameFuncs := []func(*Game){}
+	variant := Standard
 	for _, tp := range tagPairs {
-		if strings.ToLower(tp.Key) == "fen" {
-			fenFunc, err := FEN(tp.Value)
-			if err != nil {
-				return nil, fmt.Errorf("chess: pgn decode error %s on tag %s", err.Error(), tp.Key)
+		if strings.ToLower(tp.Key) == "variant" {
+			// unknown variant names are ignored and the game is read
+			// as standard chess
+			if v, err := ParseVariant(tp.Value); err == nil {
+				variant = v
 			}
-			gameFuncs = append(gameFuncs, fenFunc)
 			break
 		}
 	}
+	if !supportedVariants[variant] {
+		return nil, fmt.Errorf("chess: pgn decode error variant %s is not supported", variant)
+	}
+	fen, setUp := "", ""
+	for _, tp := range tagPairs {
+		switch strings.ToLower(tp.Key) {
+		case "fen":
+			if fen == "" {
+				fen = tp.Value
+			}
+		case "setup":
+			if setUp == "" {
+				setUp = tp.Value
+			}
+		}
+	}
+	// a SetUp tag of "0" means the game is from the starting position
+	if setUp == "0" {
+		fen = ""
+	}
+	if fen != "" || variant != Standard {
+		if fen == "" {
+			fen = startFEN
+		}
+		fenFunc, err := FEN(fen)
+		if variant != Standard {
+			fenFunc, err = VariantFEN(variant, fen)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("chess: pgn decode error %s on tag FEN", err.Error())
+		}
+		gameFuncs = append(gameFuncs, fenFunc)
+	}
 	gameFuncs = append(gameFuncs, TagPairs(tagPairs))
 	g := NewGame(gameFuncs...)
 	g.ignoreAutomaticDraws = true
+	g.initialComments = comments
 	decoder := multiDecoder([]Decoder{AlgebraicNotation{}, LongAlgebraicNotation{}, UCINotation{}})
 	for _, move := range moveComments {
+		moveNum := pgnMoveNumber(g.Position())
+		variations, err := decodePGNVariations(decoder, g.Position(), nil, move.Variations)
+		if err != nil {
+			return nil, err
+		}
 		m, err := decoder.Decode(g.Position(), move.MoveStr)
 		if err != nil {
-			return nil, fmt.Errorf("chess: pgn decode error %s on move %d", err.Error(), g.Position().moveCount)
+			return nil, fmt.Errorf("chess: pgn decode error %s on move %s", err.Error(), moveNum)
 		}
 		if err := g.Move(m); err != nil {
-			return nil, fmt.Errorf("chess: pgn invalid move error %s on move %d", err.Error(), g.Position().moveCount)
+			return nil, fmt.Errorf("chess: pgn invalid move error %s on move %s", err.Error(), moveNum)
 		}
 		g.comments = append(g.comments, move.Comments)
+		g.nags = append(g.nags, move.NAGs)
+		g.variations = append(g.variations, variations)
+	}
+	// the method is computed from the final position even though the
+	// outcome is the recorded result so they can disagree, see Verify
+	if g.method == NoMethod {
+		_, g.method = g.positionOutcome()
 	}
 	g.outcome = outcome
 	return g, nil
 }
 
+// decodePGNVariations decodes the variation lines that are alternatives
+// to the move played from pos and returns the first node of each line.
+func decodePGNVariations(d Decoder, pos *Position, parent *MoveNode, lines [][]*moveWithComment) ([]*MoveNode, error) {
+	nodes := []*MoveNode{}
+	for _, line := range lines {
+		n, err := decodePGNLine(d, pos, parent, line)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n...)
+	}
+	return nodes, nil
+}
+
+// decodePGNLine decodes the line of moves starting from pos and returns
+// the node of its first move followed by the nodes of any variations
+// that are alternatives to it.
+func decodePGNLine(d Decoder, pos *Position, parent *MoveNode, line []*moveWithComment) ([]*MoveNode, error) {
+	if len(line) == 0 {
+		return nil, nil
+	}
+	move := line[0]
+	moveNum := pgnMoveNumber(pos)
+	m, err := d.Decode(pos, move.MoveStr)
+	if err != nil {
+		return nil, fmt.Errorf("chess: pgn decode error %s on variation move %s", err.Error(), moveNum)
+	}
+	valid := moveSlice(pos.ValidMoves()).find(m)
+	if valid == nil {
+		return nil, fmt.Errorf("chess: pgn invalid move %s on variation move %s", m, moveNum)
+	}
+	n := &MoveNode{move: valid, pos: pos.Update(valid), comments: move.Comments, nags: move.NAGs, parent: parent}
+	if n.children, err = decodePGNLine(d, n.pos, n, line[1:]); err != nil {
+		return nil, err
+	}
+	variations, err := decodePGNVariations(d, pos, parent, move.Variations)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*MoveNode{n}, variations...), nil
+}
+
+// MoveNumberLabel returns the PGN move number label for the zero based
+// ply of the game, "1." for White's first move and "1..." for Black's.
+// Numbering continues from the full move number and side to move of the
+// game's starting position so games from a FEN with Black to move start
+// with a label such as "23...".  An empty string is returned for
+// negative plies.
+func (g *Game) MoveNumberLabel(ply int) string {
+	if ply < 0 {
+		return ""
+	}
+	start := g.positions[0]
+	if start.Turn() == Black {
+		ply++
+	}
+	moveNum := fmt.Sprintf("%d.", start.moveCount+ply/2)
+	if ply%2 == 1 {
+		moveNum += ".."
+	}
+	return moveNum
+}
+
+func pgnMoveNumber(pos *Position) string {
+	moveNum := fmt.Sprintf("%d.", pos.moveCount)
+	if pos.Turn() == Black {
+		moveNum += ".."
+	}
+	return moveNum
+}
+
+// sevenTagRoster is the required set of PGN tag pairs in their
+// export order along with the value used when a game doesn't set one.
+var sevenTagRoster = []TagPair{
+	{Key: "Event", Value: "?"},
+	{Key: "Site", Value: "?"},
+	{Key: "Date", Value: "????.??.??"},
+	{Key: "Round", Value: "?"},
+	{Key: "White", Value: "?"},
+	{Key: "Black", Value: "?"},
+	{Key: "Result", Value: string(NoOutcome)},
+}
+
 func encodePGN(g *Game) string {
 	s := ""
-	for _, tag := range g.tagPairs {
+	for _, tag := range pgnTagPairs(g) {
 		s += fmt.Sprintf("[%s \"%s\"]\n", tag.Key, tag.Value)
 	}
 	s += "\n"
-	for i, move := range g.moves {
-		pos := g.positions[i]
-		txt := g.notation.Encode(pos, move)
-		if i%2 == 0 {
-			s += fmt.Sprintf("%d. %s", (i/2)+1, txt)
-		} else {
-			s += fmt.Sprintf(" %s ", txt)
+	tree := g.Tree()
+	tokens := []string{}
+	for _, c := range tree.comments {
+		tokens = append(tokens, "{"+c+"}")
+	}
+	tokens = append(tokens, encodePGNLine(g.notation, tree, true)...)
+	tokens = append(tokens, string(g.outcome))
+	return s + wrapPGNTokens(tokens, g.pgnLineWidth)
+}
+
+// encodePGNLine returns the movetext tokens of the line continuing from
+// n with each variation in parentheses after the move it replaces.  Black
+// moves are given a move number when numbered is true or when they
+// follow a comment or variation.
+func encodePGNLine(e Encoder, n *MoveNode, numbered bool) []string {
+	tokens := []string{}
+	for ; len(n.children) > 0; n = n.children[0] {
+		main := n.children[0]
+		tokens = append(tokens, encodePGNMove(e, n.pos, main, numbered)...)
+		numbered = len(main.comments) > 0
+		for _, v := range n.children[1:] {
+			vTokens := encodePGNMove(e, n.pos, v, true)
+			vTokens = append(vTokens, encodePGNLine(e, v, len(v.comments) > 0)...)
+			vTokens[0] = "(" + vTokens[0]
+			vTokens[len(vTokens)-1] += ")"
+			tokens = append(tokens, vTokens...)
+			numbered = true
 		}
-		if len(g.comments) > i {
-			for _, c := range g.comments[i] {
-				s += " { " + c + " } "
+	}
+	return tokens
+}
+
+// encodePGNMove returns the tokens for the node's move, played from pos,
+// its numeric annotation glyphs and its comments.
+func encodePGNMove(e Encoder, pos *Position, n *MoveNode, numbered bool) []string {
+	// move numbers are kept with their move so wrapping can't split them
+	txt := e.Encode(pos, n.move)
+	if pos.Turn() == White {
+		txt = fmt.Sprintf("%d. %s", pos.moveCount, txt)
+	} else if numbered {
+		txt = fmt.Sprintf("%d... %s", pos.moveCount, txt)
+	}
+	tokens := []string{txt}
+	for _, nag := range n.nags {
+		tokens = append(tokens, fmt.Sprintf("$%d", nag))
+	}
+	for _, c := range n.comments {
+		tokens = append(tokens, "{"+c+"}")
+	}
+	return tokens
+}
+
+// pgnTagPairs returns the game's tag pairs with the seven tag roster
+// first, then the SetUp and FEN tags of games from a FEN, followed by
+// any other tag pairs in the order they were added.
+func pgnTagPairs(g *Game) []*TagPair {
+	tagPairs := []*TagPair{}
+	for _, str := range sevenTagRoster {
+		tag := &TagPair{Key: str.Key, Value: str.Value}
+		if tp := g.GetTagPair(str.Key); tp != nil {
+			tag.Value = tp.Value
+		}
+		if tag.Key == "Result" {
+			tag.Value = string(g.outcome)
+		}
+		tagPairs = append(tagPairs, tag)
+	}
+	// games that don't start from the starting position are given SetUp
+	// and FEN tags replacing any the game has
+	fen := g.positions[0].String()
+	setUp := fen != startFEN
+	if setUp {
+		tagPairs = append(tagPairs, &TagPair{Key: "SetUp", Value: "1"}, &TagPair{Key: "FEN", Value: fen})
+	}
+	for _, tag := range g.tagPairs {
+		if isSevenTagRosterKey(tag.Key) || (setUp && (tag.Key == "SetUp" || tag.Key == "FEN")) {
+			continue
+		}
+		tagPairs = append(tagPairs, tag)
+	}
+	return tagPairs
+}
+
+func isSevenTagRosterKey(k string) bool {
+	for _, str := range sevenTagRoster {
+		if str.Key == k {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapPGNTokens joins the tokens with spaces, starting a new line
+// before any token that would exceed the line width.  Tokens are never
+// split so a line may exceed the width if a single token does.  A
+// width of zero or less disables wrapping.
+func wrapPGNTokens(tokens []string, width int) string {
+	s := ""
+	lineLen := 0
+	for i, token := range tokens {
+		if i > 0 {
+			if width > 0 && lineLen+1+len(token) > width {
+				s += "\n"
+				lineLen = 0
+			} else {
+				s += " "
+				lineLen++
 			}
 		}
+		s += token
+		lineLen += len(token)
 	}
-	s += " " + string(g.outcome)
 	return s
 }
 
@@ -225,37 +602,132 @@ func getTagPairs(pgn string) []*TagPair {
 }
 
 type moveWithComment struct {
-	MoveStr  string
-	Comments []string
+	MoveStr    string
+	Comments   []string
+	NAGs       []int
+	Variations [][]*moveWithComment
 }
 
-var moveListTokenRe = regexp.MustCompile(`(?:\d+\.)|(O-O(?:-O)?|\w*[abcdefgh][12345678]\w*(?:=[QRBN])?(?:\+|#)?)|(?:\{([^}]*)\})|(?:\([^)]*\))|(\*|0-1|1-0|1\/2-1\/2)`)
+var moveListTokenRe = regexp.MustCompile(`(?:\d+\.)|(O-O(?:-O)?|[PNBRQ]?@[abcdefgh][12345678](?:\+|#)?|\w*[abcdefgh][12345678]\w*(?:=[QRBN])?(?:\+|#)?)|(\$\d+|[!?]{1,2})|([()])|(\*|0-1|1-0|1\/2-1\/2)`)
 
-func moveListWithComments(pgn string) ([]moveWithComment, Outcome) {
+// pgnToken is a movetext token with only the field for its kind set.
+type pgnToken struct {
+	move    string
+	comment string
+	nag     string
+	paren   string
+	outcome string
+}
+
+// pgnTokens splits the movetext into tokens.  Comments are found by
+// matching braces rather than with moveListTokenRe so that they can
+// contain nested braces.  A comment missing its closing brace runs to
+// the end of the movetext.
+func pgnTokens(pgn string) []pgnToken {
+	tokens := []pgnToken{}
+	for pgn != "" {
+		i := strings.IndexByte(pgn, '{')
+		if i < 0 {
+			i = len(pgn)
+		}
+		for _, match := range moveListTokenRe.FindAllStringSubmatch(pgn[:i], -1) {
+			tokens = append(tokens, pgnToken{move: match[1], nag: match[2], paren: match[3], outcome: match[4]})
+		}
+		if i == len(pgn) {
+			break
+		}
+		depth, j := 0, i
+		for ; j < len(pgn); j++ {
+			if pgn[j] == '{' {
+				depth++
+			} else if pgn[j] == '}' {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		if j == len(pgn) {
+			tokens = append(tokens, pgnToken{comment: pgn[i+1:]})
+			break
+		}
+		tokens = append(tokens, pgnToken{comment: pgn[i+1 : j]})
+		pgn = pgn[j+1:]
+	}
+	return tokens
+}
+
+// pgnVariation is a variation being parsed along with the line it
+// branches from and the move in that line it is an alternative to.
+type pgnVariation struct {
+	parent []*moveWithComment
+	move   *moveWithComment
+}
+
+// moveListWithComments returns the main line of the movetext, the
+// comments before its first move and its outcome.
+func moveListWithComments(pgn string) ([]*moveWithComment, []string, Outcome) {
 	pgn = stripTagPairs(pgn)
 	var outcome Outcome
-	moves := []moveWithComment{}
+	moves := []*moveWithComment{}
+	comments := []string{}
+	variations := []pgnVariation{}
+	closeVariation := func() {
+		v := variations[len(variations)-1]
+		variations = variations[:len(variations)-1]
+		if v.move != nil && len(moves) > 0 {
+			v.move.Variations = append(v.move.Variations, moves)
+		}
+		moves = v.parent
+	}
 
-	for _, match := range moveListTokenRe.FindAllStringSubmatch(pgn, -1) {
-		move, commentText, outcomeText := match[1], match[2], match[3]
-		if len(move+commentText+outcomeText) == 0 {
+	for _, token := range pgnTokens(pgn) {
+		move, commentText, nagText, paren, outcomeText := token.move, token.comment, token.nag, token.paren, token.outcome
+		if len(move+commentText+nagText+paren+outcomeText) == 0 {
 			continue
 		}
 
 		if outcomeText != "" {
+			if len(variations) > 0 {
+				continue
+			}
 			outcome = Outcome(outcomeText)
 			break
 		}
 
-		if commentText != "" {
+		if paren == "(" {
+			v := pgnVariation{parent: moves}
+			if len(moves) > 0 {
+				v.move = moves[len(moves)-1]
+			}
+			variations = append(variations, v)
+			moves = []*moveWithComment{}
+		} else if paren == ")" && len(variations) > 0 {
+			closeVariation()
+		}
+
+		if nagText != "" && len(moves) > 0 {
+			nag := NAGFromSymbol(nagText)
+			if strings.HasPrefix(nagText, "$") {
+				nag, _ = strconv.Atoi(nagText[1:])
+			}
+			moves[len(moves)-1].NAGs = append(moves[len(moves)-1].NAGs, nag)
+		}
+
+		if commentText != "" && len(moves) > 0 {
 			moves[len(moves)-1].Comments = append(moves[len(moves)-1].Comments, strings.TrimSpace(commentText))
+		} else if commentText != "" && len(variations) == 0 {
+			comments = append(comments, strings.TrimSpace(commentText))
 		}
 
 		if move != "" {
-			moves = append(moves, moveWithComment{MoveStr: move})
+			moves = append(moves, &moveWithComment{MoveStr: move})
 		}
 	}
-	return moves, outcome
+	for len(variations) > 0 {
+		closeVariation()
+	}
+	return moves, comments, outcome
 }
 
 func stripTagPairs(pgn string) string {
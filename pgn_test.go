@@ -1,8 +1,10 @@
 package chess
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -67,6 +69,68 @@ func TestValidPGNs(t *testing.T) {
 	}
 }
 
+func TestPGNWithAnnotations(t *testing.T) {
+	pgn := "[Event \"Annotations\"]\n\n1. e4! $1 e5?! 2. Nf3 $14 Nc6 {a comment} 3. Bb5!! a6?? 1-0"
+	game, err := decodePGN(pgn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(game.Moves()) != 6 {
+		t.Fatalf("expected 6 moves but got %d", len(game.Moves()))
+	}
+	if game.Outcome() != WhiteWon {
+		t.Fatalf("expected outcome %s but got %s", WhiteWon, game.Outcome())
+	}
+	expected := [][]int{{1, 1}, {6}, {14}, nil, {3}, {4}}
+	if !reflect.DeepEqual(game.NAGs(), expected) {
+		t.Fatalf("expected nags %v but got %v", expected, game.NAGs())
+	}
+	movetext := "1. e4 $1 $1 e5 $6 2. Nf3 $14 Nc6 {a comment} 3. Bb5 $3 a6 $4 1-0"
+	if !strings.HasSuffix(game.String(), "\n\n"+movetext) {
+		t.Fatalf("expected movetext %s but got %s", movetext, game.String())
+	}
+	cp, err := decodePGN(game.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cp.NAGs(), expected) {
+		t.Fatalf("expected nags %v after round trip but got %v", expected, cp.NAGs())
+	}
+}
+
+func TestNAGSymbol(t *testing.T) {
+	for nag, sym := range map[int]string{1: "!", 2: "?", 3: "!!", 4: "??", 5: "!?", 6: "?!"} {
+		if NAGSymbol(nag) != sym {
+			t.Fatalf("expected nag %d to have symbol %s but got %s", nag, sym, NAGSymbol(nag))
+		}
+		if NAGFromSymbol(sym) != nag {
+			t.Fatalf("expected symbol %s to be nag %d but got %d", sym, nag, NAGFromSymbol(sym))
+		}
+	}
+	if NAGSymbol(14) != "" || NAGFromSymbol("+-") != 0 {
+		t.Fatal("expected unknown nags and symbols to have no mapping")
+	}
+}
+
+func TestInvalidPGNMoveNumber(t *testing.T) {
+	tests := []struct {
+		pgn     string
+		moveNum string
+	}{
+		{"1. e4 e5 2. Nf3 Nc6 3. Ke3 a6 *", "on move 3."},
+		{"1. e4 e5 2. Nf3 Nc6 3. Bb5 Ke6 *", "on move 3..."},
+	}
+	for _, test := range tests {
+		_, err := decodePGN(test.pgn)
+		if err == nil {
+			t.Fatalf("expected pgn %s to be invalid", test.pgn)
+		}
+		if !strings.HasSuffix(err.Error(), test.moveNum) {
+			t.Fatalf("expected error to end with %s but got %s", test.moveNum, err.Error())
+		}
+	}
+}
+
 type commentTest struct {
 	PGN         string
 	MoveNumber  int
@@ -120,6 +184,41 @@ func TestNewGameComments(t *testing.T) {
 	}
 }
 
+func TestPGNNestedAndInitialComments(t *testing.T) {
+	movetext := "{intro {nested} text} 1. e4 {best {by test}} 1... e5 (1... c5 {sicilian}) 2. Nf3 *"
+	game, err := decodePGN(movetext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Comment(0) != "intro {nested} text" {
+		t.Fatalf("expected initial comment %q but got %q", "intro {nested} text", game.Comment(0))
+	}
+	if game.Comment(1) != "best {by test}" {
+		t.Fatalf("expected comment %q but got %q", "best {by test}", game.Comment(1))
+	}
+	if c := game.Tree().Next().Next().Variations()[0].Comments(); len(c) != 1 || c[0] != "sicilian" {
+		t.Fatalf("expected variation comment sicilian but got %v", c)
+	}
+	if !strings.HasSuffix(game.String(), "\n\n"+movetext) {
+		t.Fatalf("expected movetext %s but got %s", movetext, game.String())
+	}
+}
+
+func TestScannerInitialComment(t *testing.T) {
+	pgn := "[Event \"A\"]\n\n{intro} 1. e4 e5 *\n\n[Event \"B\"]\n\n1. d4 *\n"
+	scanner := NewScanner(strings.NewReader(pgn))
+	games := []*Game{}
+	for scanner.Scan() {
+		games = append(games, scanner.Next())
+	}
+	if len(games) != 2 {
+		t.Fatalf("expected 2 games but got %d", len(games))
+	}
+	if games[0].Comment(0) != "intro" || len(games[0].Moves()) != 2 {
+		t.Fatalf("expected first game to have initial comment and 2 moves but got %q and %d", games[0].Comment(0), len(games[0].Moves()))
+	}
+}
+
 func TestWriteComments(t *testing.T) {
 	pgn := mustParsePGN("fixtures/pgns/0005.pgn")
 	game, err := decodePGN(pgn)
@@ -135,6 +234,247 @@ func TestWriteComments(t *testing.T) {
 	}
 }
 
+func TestEncodePGN(t *testing.T) {
+	g := NewGame()
+	g.AddTagPair("WhiteElo", "2000")
+	g.AddTagPair("Event", "Test")
+	for _, m := range []string{"e4", "e5", "Nf3"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := `[Event "Test"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "?"]
+[Black "?"]
+[Result "*"]
+[WhiteElo "2000"]
+
+1. e4 e5 2. Nf3 *`
+	if g.String() != expected {
+		t.Fatalf("expected pgn\n%s\nbut got\n%s", expected, g.String())
+	}
+}
+
+func TestEncodePGNFromBlackToMove(t *testing.T) {
+	fen, err := FEN("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame(fen)
+	for _, m := range []string{"e5", "Nf3"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pgn := g.String()
+	if !strings.HasSuffix(pgn, "12... e5 13. Nf3 *") {
+		t.Fatalf("expected movetext to start from move 12 but got %s", pgn)
+	}
+}
+
+func TestEncodePGNLineWidth(t *testing.T) {
+	pgn := mustParsePGN("fixtures/pgns/0005.pgn")
+	opt, err := PGN(strings.NewReader(pgn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame(opt, PGNLineWidth(80))
+	lines := strings.Split(g.String(), "\n")
+	for _, line := range lines {
+		if len([]rune(line)) > 80 && !strings.HasPrefix(line, "[") {
+			t.Fatalf("expected line to be at most 80 characters but got %s", line)
+		}
+	}
+	cp, err := decodePGN(g.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Position().String() != g.Position().String() {
+		t.Fatalf("expected position %s but got %s", g.Position(), cp.Position())
+	}
+	if len(cp.Comments()[7]) != 2 {
+		t.Fatalf("expected %d comments for move 7 but got %d", 2, len(cp.Comments()[7]))
+	}
+}
+
+func TestPGNVariations(t *testing.T) {
+	movetext := "1. e4 (1. d4 d5 (1... Nf6 2. c4) 2. c4) (1. c4) 1... e5 {main} (1... c5 2. Nf3) 2. Nf3 *"
+	game, err := decodePGN(movetext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(game.Moves()) != 3 {
+		t.Fatalf("expected 3 main line moves but got %d", len(game.Moves()))
+	}
+	root := game.Tree()
+	if root.Move() != nil || root.Parent() != nil {
+		t.Fatal("expected root node to have no move or parent")
+	}
+	children := root.Children()
+	if len(children) != 3 {
+		t.Fatalf("expected 3 first moves but got %d", len(children))
+	}
+	for i, s := range []string{"e2e4", "d2d4", "c2c4"} {
+		if children[i].Move().String() != s {
+			t.Fatalf("expected child %d to be %s but got %s", i, s, children[i].Move())
+		}
+	}
+	e4 := children[0]
+	if len(e4.Variations()) != 2 || e4.Variations()[0] != children[1] {
+		t.Fatalf("expected e4 to have variations d4 and c4 but got %v", e4.Variations())
+	}
+	d5 := children[1].Next()
+	if d5.Move().String() != "d7d5" || d5.Parent() != children[1] {
+		t.Fatalf("expected d4 to continue with d5 but got %s", d5.Move())
+	}
+	if v := d5.Variations(); len(v) != 1 || v[0].Move().String() != "g8f6" || v[0].Next().Move().String() != "c2c4" {
+		t.Fatalf("expected d5 to have variation Nf6 c4 but got %v", v)
+	}
+	if children[2].Next() != nil {
+		t.Fatalf("expected c4 variation to end but got %s", children[2].Next().Move())
+	}
+	e5 := e4.Next()
+	if len(e5.Comments()) != 1 || e5.Comments()[0] != "main" {
+		t.Fatalf("expected e5 comment main but got %v", e5.Comments())
+	}
+	if e5.Position().String() != game.Positions()[2].String() {
+		t.Fatalf("expected e5 position %s but got %s", game.Positions()[2], e5.Position())
+	}
+	if !strings.HasSuffix(game.String(), "\n\n"+movetext) {
+		t.Fatalf("expected movetext %s but got %s", movetext, game.String())
+	}
+}
+
+func TestCursor(t *testing.T) {
+	game, err := decodePGN("1. e4 (1. d4 d5 (1... Nf6 2. c4) 2. c4) 1... e5 2. Nf3 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := game.Cursor()
+	if c.Move() != nil || c.Ply() != 0 || c.Position() != game.Positions()[0] || c.Prev() {
+		t.Fatal("expected the cursor to start at the starting position")
+	}
+	for i, m := range game.Moves() {
+		if !c.Next() || c.Move().String() != m.String() || c.Ply() != i+1 {
+			t.Fatalf("expected move %s at ply %d but got %s", m, i+1, c.Move())
+		}
+		if c.Position().String() != game.Positions()[i+1].String() {
+			t.Fatalf("expected position %s but got %s", game.Positions()[i+1], c.Position())
+		}
+	}
+	if c.Next() {
+		t.Fatal("expected the main line to end")
+	}
+	if !c.Prev() || c.Move().String() != "e7e5" {
+		t.Fatalf("expected to step back to e5 but got %s", c.Move())
+	}
+	if err := c.Goto(0); err != nil || c.Move() != nil {
+		t.Fatalf("expected to go to the starting position but got %s %v", c.Move(), err)
+	}
+	// walk into the d4 variation and its Nf6 subvariation
+	if !c.Branch(1) || c.Move().String() != "d2d4" || c.Branch(2) {
+		t.Fatalf("expected to enter the d4 variation but got %s", c.Move())
+	}
+	if !c.Branch(1) || c.Move().String() != "g8f6" || !c.Next() || c.Move().String() != "c2c4" {
+		t.Fatalf("expected the Nf6 variation but got %s", c.Move())
+	}
+	if len(c.Node().Variations()) != 0 || c.Ply() != 3 {
+		t.Fatalf("expected to be at ply 3 of the variation but got %d", c.Ply())
+	}
+	if err := c.Goto(5); err == nil || c.Move().String() != "c2c4" {
+		t.Fatal("expected an error going past the end of the variation")
+	}
+	// leave the variations by stepping back to where they branched
+	if err := c.Goto(1); err != nil || c.Move().String() != "d2d4" {
+		t.Fatalf("expected d4 at ply 1 but got %s %v", c.Move(), err)
+	}
+	if !c.Prev() || !c.Next() || c.Move().String() != "e2e4" {
+		t.Fatalf("expected to return to the main line but got %s", c.Move())
+	}
+	if err := c.Goto(3); err != nil || c.Move().String() != "g1f3" {
+		t.Fatalf("expected Nf3 at ply 3 but got %s %v", c.Move(), err)
+	}
+	// the cursor isn't changed by later moves
+	if err := game.MoveStr("Nc6"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Next() {
+		t.Fatal("expected the cursor's line to end at Nf3")
+	}
+}
+
+func TestPGNVariationsFixture(t *testing.T) {
+	game, err := decodePGN(mustParsePGN("fixtures/pgns/0003.pgn"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exd5 := game.Tree().Next().Next()
+	v := exd5.Variations()
+	if len(v) != 1 || v[0].Move().String() != "h6g5" {
+		t.Fatalf("expected exd5 to have variation hxg5 but got %v", v)
+	}
+	if !strings.HasSuffix(game.String(), "1. Nd5 exd5 (1... hxg5 2. Nxe7+ Nxe7) 2. Bxf6 hxg5 3. Bxe7 1-0") {
+		t.Fatalf("expected variation to be exported but got %s", game.String())
+	}
+	for len(game.Moves()) > 1 {
+		if err := game.Undo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(game.Tree().Next().Children()) != 0 {
+		t.Fatal("expected undo to remove the variation")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	pgns := []string{
+		"1. e4 e5 2. Nf3 (2. Bc4) Nc6 1-0",
+		"1. e4 c5 2. Nf3 *",
+		"1. e4 e5 2. Nf3 Nc6 3. Bb5 *",
+		"1. e4 e5 2. Bc4 {bishop} Bc5 *",
+	}
+	games := []*Game{}
+	for _, pgn := range pgns {
+		g, err := decodePGN(pgn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		games = append(games, g)
+	}
+	merged, err := Merge(games...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	movetext := "1. e4 e5 (1... c5 2. Nf3) 2. Nf3 (2. Bc4 Bc5) 2... Nc6 3. Bb5 *"
+	if !strings.HasSuffix(merged.String(), movetext) {
+		t.Fatalf("expected movetext %s but got %s", movetext, merged.String())
+	}
+	if len(games[0].Moves()) != 4 || games[0].Outcome() != WhiteWon {
+		t.Fatal("expected merging not to change the first game")
+	}
+	merged, err = Merge(games[2], games[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	movetext = "1. e4 e5 2. Nf3 (2. Bc4) 2... Nc6 3. Bb5 *"
+	if !strings.HasSuffix(merged.String(), movetext) {
+		t.Fatalf("expected movetext %s but got %s", movetext, merged.String())
+	}
+	fen, err := FEN("7k/8/8/8/8/8/8/R6K w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Merge(games[0], NewGame(fen)); err == nil {
+		t.Fatal("expected an error merging games with different starting positions")
+	}
+	if _, err := Merge(); err == nil {
+		t.Fatal("expected an error merging no games")
+	}
+}
+
 func TestScanner(t *testing.T) {
 	for _, fname := range []string{"fixtures/pgns/0006.pgn", "fixtures/pgns/0007.pgn"} {
 		f, err := os.Open(fname)
@@ -154,6 +494,79 @@ func TestScanner(t *testing.T) {
 	}
 }
 
+func TestPGNScanner(t *testing.T) {
+	for _, fname := range []string{"fixtures/pgns/0006.pgn", "fixtures/pgns/0007.pgn"} {
+		f, err := os.Open(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		scanner := NewPGNScanner(f)
+		count := 0
+		for scanner.Scan() {
+			if _, err := scanner.Game(); err != nil {
+				t.Fatal(err)
+			}
+			count++
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if count != 5 {
+			t.Fatalf(fname+" expected 5 games but got %d", count)
+		}
+	}
+}
+
+func TestPGNScannerBoundaries(t *testing.T) {
+	pgn := `[Event "one"]
+
+1. e4 {a comment
+
+with a blank line and 1-0 in it} e5 (1... c5 2. Nf3 *) 2. Nf3 0-1
+[Event "two"]
+1. d4 d5
+[Event "three"]
+
+1. c4 {nested {braces} 1/2-1/2} *
+[Event "four"]
+
+1. e5 1-0
+
+[Event "five"]
+
+1. Nf3 1/2-1/2`
+	scanner := NewPGNScanner(strings.NewReader(pgn))
+	type result struct {
+		event   string
+		moves   int
+		outcome Outcome
+		err     bool
+	}
+	expected := []result{
+		{"one", 3, BlackWon, false},
+		{"two", 2, "", false},
+		{"three", 1, NoOutcome, false},
+		{"four", 0, "", true},
+		{"five", 1, Draw, false},
+	}
+	results := []result{}
+	for scanner.Scan() {
+		game, err := scanner.Game()
+		if err != nil {
+			results = append(results, result{event: getTagPairs(pgn)[len(results)].Value, err: true})
+			continue
+		}
+		results = append(results, result{game.GetTagPair("Event").Value, len(game.Moves()), game.Outcome(), false})
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("expected games %v but got %v", expected, results)
+	}
+}
+
 func BenchmarkPGN(b *testing.B) {
 	pgn := mustParsePGN("fixtures/pgns/0001.pgn")
 	b.ResetTimer()
@@ -187,3 +600,150 @@ func TestGamesFromPGN(t *testing.T) {
 		}
 	}
 }
+
+func TestGameVerify(t *testing.T) {
+	tests := []struct {
+		pgn    string
+		method Method
+		errs   int
+	}{
+		// the recorded draw contradicts the checkmate
+		{`[Result "1/2-1/2"]
+
+1. f3 e5 2. g4 Qh4# 1/2-1/2`, Checkmate, 1},
+		{`[Result "0-1"]
+
+1. f3 e5 2. g4 Qh4# 0-1`, Checkmate, 0},
+		// a resignation can't be derived from the position
+		{`[Result "1-0"]
+
+1. e4 e5 1-0`, NoMethod, 0},
+		// the Result tag disagrees with the movetext
+		{`[Result "1-0"]
+
+1. e4 e5 0-1`, NoMethod, 1},
+		{`[FEN "4k3/8/8/8/8/8/3q4/4K3 w - - 0 1"]
+[Result "0-1"]
+
+1. Kxd2 0-1`, InsufficientMaterial, 1},
+		// games without a result aren't checked
+		{`1. f3 e5 2. g4 Qh4#`, Checkmate, 0},
+		{`1. f3 e5 2. g4 Qh4# *`, Checkmate, 0},
+	}
+	for _, test := range tests {
+		opt, err := PGN(strings.NewReader(test.pgn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := NewGame(opt)
+		if g.Method() != test.method {
+			t.Fatalf("expected method %s for %s but got %s", test.method, test.pgn, g.Method())
+		}
+		errs := g.Verify()
+		if len(errs) != test.errs {
+			t.Fatalf("expected %d errors for %s but got %v", test.errs, test.pgn, errs)
+		}
+		for _, err := range errs {
+			if !errors.Is(err, ErrResultMismatch) {
+				t.Fatalf("expected a result mismatch but got %v", err)
+			}
+		}
+	}
+	g := NewGame()
+	for _, m := range []string{"f3", "e5", "g4", "Qh4#"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if errs := g.Verify(); len(errs) != 0 {
+		t.Fatalf("expected no errors for a played game but got %v", errs)
+	}
+}
+
+func TestGameMoveNumberLabel(t *testing.T) {
+	g := NewGame()
+	for ply, label := range []string{"1.", "1...", "2.", "2...", "3."} {
+		if s := g.MoveNumberLabel(ply); s != label {
+			t.Fatalf("expected label %s for ply %d but got %s", label, ply, s)
+		}
+	}
+	fen, err := FEN("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 2 23")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g = NewGame(fen)
+	for ply, label := range []string{"23...", "24.", "24...", "25."} {
+		if s := g.MoveNumberLabel(ply); s != label {
+			t.Fatalf("expected label %s for ply %d but got %s", label, ply, s)
+		}
+	}
+	if s := g.MoveNumberLabel(-1); s != "" {
+		t.Fatalf("expected no label for a negative ply but got %s", s)
+	}
+	// the labels match the move numbers of the game's own positions
+	for _, m := range []string{"Nf6", "Nc3", "Bc5"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for ply, pos := range g.Positions()[:3] {
+		if s := g.MoveNumberLabel(ply); s != pgnMoveNumber(pos) {
+			t.Fatalf("expected label %s for ply %d but got %s", pgnMoveNumber(pos), ply, s)
+		}
+	}
+}
+
+func TestPGNSetUpAndFENTags(t *testing.T) {
+	const fen = "r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4"
+	pgn := `[Event "Puzzle"]
+[SetUp "1"]
+[FEN "` + fen + `"]
+
+4. Qxf7# 1-0`
+	g := NewGame()
+	if err := g.UnmarshalText([]byte(pgn)); err != nil {
+		t.Fatal(err)
+	}
+	if g.Positions()[0].String() != fen || g.Method() != Checkmate {
+		t.Fatalf("expected the game to start from %s but got %s", fen, g.Positions()[0])
+	}
+	// the tags are written once with the game's starting position
+	out := g.String()
+	if strings.Count(out, `[SetUp "1"]`) != 1 || strings.Count(out, `[FEN "`+fen+`"]`) != 1 {
+		t.Fatalf("expected SetUp and FEN tags once in %s", out)
+	}
+	opt, err := FEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g = NewGame(opt)
+	if err := g.MoveStr("Qxf7#"); err != nil {
+		t.Fatal(err)
+	}
+	out = g.String()
+	if !strings.Contains(out, "[SetUp \"1\"]\n[FEN \""+fen+"\"]\n") {
+		t.Fatalf("expected SetUp and FEN tags in %s", out)
+	}
+	cp := NewGame()
+	if err := cp.UnmarshalText([]byte(out)); err != nil {
+		t.Fatal(err)
+	}
+	if cp.String() != out {
+		t.Fatalf("expected %s but got %s", out, cp)
+	}
+	// games from the starting position don't have the tags
+	if out := NewGame().String(); strings.Contains(out, "SetUp") || strings.Contains(out, "FEN") {
+		t.Fatalf("expected no SetUp or FEN tags in %s", out)
+	}
+	// a SetUp tag of 0 means the starting position
+	pgn = `[SetUp "0"]
+[FEN "` + fen + `"]
+
+1. e4 *`
+	if err := g.UnmarshalText([]byte(pgn)); err != nil {
+		t.Fatal(err)
+	}
+	if g.Positions()[0].String() != startFEN {
+		t.Fatalf("expected the game to start from the starting position but got %s", g.Positions()[0])
+	}
+}
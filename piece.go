@@ -1,5 +1,7 @@
 package chess
 
+import "strings"
+
 // Color represents the color of a chess piece.
 type Color int8
 
@@ -71,6 +73,9 @@ func PieceTypes() [6]PieceType {
 	return [6]PieceType{King, Queen, Rook, Bishop, Knight, Pawn}
 }
 
+// String implements the fmt.Stringer interface and returns the
+// piece type's lowercase FEN letter such as "n" or an empty string
+// for NoPieceType.
 func (p PieceType) String() string {
 	switch p {
 	case King:
@@ -89,6 +94,27 @@ func (p PieceType) String() string {
 	return ""
 }
 
+// FENChar returns the piece type's FEN letter for the given color which
+// is uppercase for White and lowercase for Black.  Zero is returned for
+// NoPieceType.
+func (p PieceType) FENChar(c Color) rune {
+	s := p.String()
+	if s == "" {
+		return 0
+	}
+	if c == White {
+		s = strings.ToUpper(s)
+	}
+	return rune(s[0])
+}
+
+// SANLetter returns the uppercase letter used for the piece type in
+// algebraic notation such as "N".  An empty string is returned for
+// pawns and NoPieceType.
+func (p PieceType) SANLetter() string {
+	return charFromPieceType(p)
+}
+
 var (
 	strToPieceTypeMap = map[string]PieceType{
 		"k": King,
@@ -150,6 +176,17 @@ func NewPiece(t PieceType, c Color) Piece {
 	return NoPiece
 }
 
+// ParsePiece returns the piece for its FEN character, uppercase for
+// White and lowercase for Black such as 'N' for a white knight, and
+// true.  NoPiece and false are returned for any other character.
+func ParsePiece(c rune) (Piece, bool) {
+	p, ok := fenPieceMap[string(c)]
+	if !ok {
+		return NoPiece, false
+	}
+	return p, true
+}
+
 // Type returns the type of the piece.
 func (p Piece) Type() PieceType {
 	switch p {
@@ -21,3 +21,71 @@ func TestPieceString(t *testing.T) {
 		}
 	}
 }
+
+func TestColor(t *testing.T) {
+	tables := []struct {
+		color Color
+		other Color
+		str   string
+		name  string
+	}{
+		{White, Black, "w", "White"},
+		{Black, White, "b", "Black"},
+		{NoColor, NoColor, "-", "No Color"},
+	}
+	for _, table := range tables {
+		if table.color.Other() != table.other {
+			t.Errorf("expected other color of %s to be %s but got %s", table.name, table.other.Name(), table.color.Other().Name())
+		}
+		if table.color.String() != table.str || table.color.Name() != table.name {
+			t.Errorf("expected color %s %s but got %s %s", table.str, table.name, table.color.String(), table.color.Name())
+		}
+	}
+	pos := StartingPosition()
+	if pos.Turn() != White {
+		t.Fatalf("expected white to move but got %s", pos.Turn().Name())
+	}
+	if pos = pos.Update(&Move{s1: E2, s2: E4}); pos.Turn() != Black {
+		t.Fatalf("expected black to move but got %s", pos.Turn().Name())
+	}
+}
+
+func TestPieceTypeLetters(t *testing.T) {
+	tables := []struct {
+		piece PieceType
+		white rune
+		black rune
+		san   string
+	}{
+		{King, 'K', 'k', "K"},
+		{Queen, 'Q', 'q', "Q"},
+		{Rook, 'R', 'r', "R"},
+		{Bishop, 'B', 'b', "B"},
+		{Knight, 'N', 'n', "N"},
+		{Pawn, 'P', 'p', ""},
+		{NoPieceType, 0, 0, ""},
+	}
+	for _, table := range tables {
+		if table.piece.FENChar(White) != table.white || table.piece.FENChar(Black) != table.black {
+			t.Errorf("expected FEN chars %q and %q but got %q and %q", table.white, table.black, table.piece.FENChar(White), table.piece.FENChar(Black))
+		}
+		if table.piece.SANLetter() != table.san {
+			t.Errorf("expected SAN letter %q but got %q", table.san, table.piece.SANLetter())
+		}
+	}
+}
+
+func TestParsePiece(t *testing.T) {
+	for _, p := range allPieces {
+		c := p.Type().FENChar(p.Color())
+		parsed, ok := ParsePiece(c)
+		if !ok || parsed != p {
+			t.Errorf("expected %q to parse as %s but got %s %t", c, p, parsed, ok)
+		}
+	}
+	for _, c := range []rune{'x', '1', ' ', '-', 0} {
+		if p, ok := ParsePiece(c); ok || p != NoPiece {
+			t.Errorf("expected %q not to parse but got %s %t", c, p, ok)
+		}
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
 	"strings"
+	"sync/atomic"
 )
 
 // Side represents a side of the board.
@@ -26,14 +28,7 @@ type CastleRights string
 // CanCastle returns true if the given color and side combination
 // can castle, otherwise returns false.
 func (cr CastleRights) CanCastle(c Color, side Side) bool {
-	char := "k"
-	if side == QueenSide {
-		char = "q"
-	}
-	if c == White {
-		char = strings.ToUpper(char)
-	}
-	return strings.Contains(string(cr), char)
+	return strings.Contains(string(cr), castleRightsChar(c, side))
 }
 
 // String implements the fmt.Stringer interface and returns
@@ -43,7 +38,11 @@ func (cr CastleRights) String() string {
 }
 
 // Position represents the state of the game without reguard
-// to its outcome.  Position is translatable to FEN notation.
+// to its outcome.  Position is translatable to FEN notation.  Positions
+// aren't modified by their methods, besides the Unmarshal methods, so a
+// position can be read from multiple goroutines at once.  The valid
+// moves it caches are generated safely by whichever goroutine asks
+// first.
 type Position struct {
 	board           *Board
 	turn            Color
@@ -52,7 +51,20 @@ type Position struct {
 	halfMoveClock   int
 	moveCount       int
 	inCheck         bool
-	validMoves      []*Move
+	// validMoves caches the []*Move from ValidMoves and is stored
+	// atomically so positions can be shared between goroutines
+	validMoves atomic.Value
+	// chess960 positions castle with the rooks in castleRooks which
+	// are indexed by castleIndex
+	chess960    bool
+	castleRooks [4]Square
+	variant     Variant
+	// crazyhouse positions have pockets of captured pieces that can be
+	// dropped and track the promoted pieces
+	pockets  pockets
+	promoted bitboard
+	// checks are the number of checks given by each color in Three-check
+	checks [3]uint8
 }
 
 const (
@@ -66,54 +78,625 @@ func StartingPosition() *Position {
 	return pos
 }
 
+// NewPositionFromFEN returns the position described by the FEN string.
+// In addition to the checks made by the FEN game option, an error is returned
+// if either side doesn't have exactly one king or if the en passant square
+// couldn't have resulted from the previous move.
+func NewPositionFromFEN(fen string) (*Position, error) {
+	pos, err := decodeFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateFENPosition(pos); err != nil {
+		return nil, err
+	}
+	pos.inCheck = isInCheck(pos)
+	return pos, nil
+}
+
+// ParseFENLenient is like NewPositionFromFEN but accepts the FENs written
+// by tools that don't follow the standard exactly.  Fields can be
+// separated by any amount of whitespace and the half move clock and move
+// count can be missing or "-" in which case they default to 0 and 1.  A
+// move count of 0 is read as 1.
+func ParseFENLenient(fen string) (*Position, error) {
+	fields := strings.Fields(fen)
+	if len(fields) < 4 || len(fields) > 6 {
+		return nil, fmt.Errorf("chess: fen invalid notiation %s must have 4 to 6 sections", fen)
+	}
+	for len(fields) < 6 {
+		fields = append(fields, "-")
+	}
+	if fields[4] == "-" {
+		fields[4] = "0"
+	}
+	if fields[5] == "-" || fields[5] == "0" {
+		fields[5] = "1"
+	}
+	return NewPositionFromFEN(strings.Join(fields, " "))
+}
+
 // Update returns a new position resulting from the given move.
 // The move itself isn't validated, if validation is needed use
 // Game's Move method.  This method is more performant for bots that
 // rely on the ValidMoves because it skips redundant validation.
 func (pos *Position) Update(m *Move) *Position {
-	moveCount := pos.moveCount
+	cp := pos.copy()
+	cp.update(m)
+	return cp
+}
+
+// update applies the move to the position in place and clears its
+// cached moves.
+func (pos *Position) update(m *Move) {
 	if pos.turn == Black {
-		moveCount++
+		pos.moveCount++
 	}
-	ncr := pos.updateCastleRights(m)
 	p := pos.board.Piece(m.s1)
-	halfMove := pos.halfMoveClock
 	if p.Type() == Pawn || m.HasTag(Capture) {
-		halfMove = 0
+		pos.halfMoveClock = 0
 	} else {
-		halfMove++
+		pos.halfMoveClock++
 	}
-	b := pos.board.copy()
-	b.update(m)
-	return &Position{
-		board:           b,
-		turn:            pos.turn.Other(),
-		castleRights:    ncr,
-		enPassantSquare: pos.updateEnPassantSquare(m),
-		halfMoveClock:   halfMove,
-		moveCount:       moveCount,
-		inCheck:         m.HasTag(Check),
+	if pos.variant == ThreeCheck && m.HasTag(Check) {
+		pos.checks[pos.turn]++
+	}
+	pos.castleRights = pos.updateCastleRights(m)
+	pos.pockets, pos.promoted = pos.updatePockets(m)
+	pos.enPassantSquare = pos.updateEnPassantSquare(m)
+	if m.drop != NoPieceType {
+		pos.board.drop(NewPiece(m.drop, pos.turn), m.s2)
+	} else {
+		pos.board.update(m)
+	}
+	pos.turn = pos.turn.Other()
+	pos.inCheck = m.HasTag(Check)
+	pos.validMoves = atomic.Value{}
+}
+
+// ApplyMoves returns the position resulting from playing the moves in
+// order after validating each of them.  The moves are applied to a
+// single copy of the position so replaying a long line doesn't allocate
+// a position per move.  An error naming the index of the first invalid
+// move is returned if a move isn't valid in the position it's played in.
+func (pos *Position) ApplyMoves(moves []*Move) (*Position, error) {
+	cp := pos.copy()
+	for i, m := range moves {
+		valid, ok := legalMove(cp, m)
+		if !ok {
+			return nil, fmt.Errorf("chess: move %d %s is not valid for position %s", i, m, cp)
+		}
+		cp.update(&valid)
+	}
+	return cp, nil
+}
+
+// Move returns a new position resulting from the given move after
+// validating it.  Unlike Update, an error is returned if the move isn't
+// one of the position's valid moves.  The receiver isn't modified so
+// positions can be shared, for example in a search tree.
+func (pos *Position) Move(m *Move) (*Position, error) {
+	valid := moveSlice(pos.ValidMoves()).find(m)
+	if valid == nil {
+		return nil, fmt.Errorf("chess: move %s is not valid for position %s", m, pos)
+	}
+	return pos.Update(valid), nil
+}
+
+// NullMove returns a new position in which the side to move has passed
+// as used by null move pruning.  The turn changes, the en passant square
+// is cleared and the half move clock is incremented while the board,
+// castle rights and move count are unchanged.  An error is returned if
+// the side to move is in check since passing would leave the king in
+// check.
+func (pos *Position) NullMove() (*Position, error) {
+	if isInCheck(pos) {
+		return nil, fmt.Errorf("chess: null move is not valid while in check for position %s", pos)
 	}
+	cp := pos.copy()
+	cp.turn = pos.turn.Other()
+	cp.enPassantSquare = NoSquare
+	cp.halfMoveClock++
+	cp.inCheck = isInCheck(cp)
+	return cp, nil
 }
 
-// ValidMoves returns a list of valid moves for the position.
+// ValidMoves returns a list of valid moves for the position.  Moves
+// are tagged with their Capture, EnPassant, Check, KingSideCastle,
+// QueenSideCastle and Drop tags and are ordered by piece, origin square
+// and destination square followed by castling moves and then Crazyhouse
+// drops so the order is stable between calls.
 func (pos *Position) ValidMoves() []*Move {
-	if pos.validMoves != nil {
-		return append([]*Move(nil), pos.validMoves...)
+	if moves := pos.cachedMoves(); moves != nil {
+		return append([]*Move(nil), moves...)
+	}
+	moves := engine{}.CalcMoves(pos, false)
+	pos.validMoves.Store(moves)
+	return append([]*Move(nil), moves...)
+}
+
+// cachedMoves returns the moves cached by ValidMoves or nil if they
+// haven't been generated.
+func (pos *Position) cachedMoves() []*Move {
+	moves, _ := pos.validMoves.Load().([]*Move)
+	return moves
+}
+
+// EachMove calls fn with each of the position's valid moves, in the same
+// order and with the same tags as ValidMoves, until fn returns false.
+// Moves are generated as they are needed and passed by value so stopping
+// early avoids the cost of generating the remaining moves.
+func (pos *Position) EachMove(fn func(m Move) bool) {
+	if moves := pos.cachedMoves(); moves != nil {
+		for _, m := range moves {
+			if !fn(*m) {
+				return
+			}
+		}
+		return
+	}
+	if !eachStandardMove(pos, ^bitboard(0), fn) {
+		return
+	}
+	for _, m := range castleMoves(pos) {
+		if !fn(*m) {
+			return
+		}
+	}
+	eachDropMove(pos, fn)
+}
+
+// NumLegalMoves returns the number of valid moves in the position.  It
+// counts the moves as they are generated with EachMove rather than
+// allocating them like ValidMoves which makes it cheap enough for
+// mobility terms in evaluation functions.
+func (pos *Position) NumLegalMoves() int {
+	if moves := pos.cachedMoves(); moves != nil {
+		return len(moves)
+	}
+	n := 0
+	pos.EachMove(func(m Move) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// ForcedMoves returns the position's only valid move if it has exactly
+// one, such as a king that has a single square to escape check to, and
+// an empty slice otherwise.  Generating the moves stops at the second
+// valid move.
+func (pos *Position) ForcedMoves() []*Move {
+	moves := []*Move{}
+	pos.EachMove(func(m Move) bool {
+		moves = append(moves, &m)
+		return len(moves) < 2
+	})
+	if len(moves) != 1 {
+		return []*Move{}
+	}
+	return moves
+}
+
+// RandomMove returns a valid move chosen uniformly at random using rng
+// and true, or nil and false if the position has no valid moves.  The same
+// rng seed always chooses the same move whether or not ValidMoves has
+// been called.  Moves are sampled as they are passed to EachMove so the
+// full list of valid moves isn't allocated.
+func (pos *Position) RandomMove(rng *rand.Rand) (*Move, bool) {
+	var chosen Move
+	n := 0
+	pos.EachMove(func(m Move) bool {
+		n++
+		if rng.Intn(n) == 0 {
+			chosen = m
+		}
+		return true
+	})
+	if n == 0 {
+		return nil, false
+	}
+	return &chosen, true
+}
+
+// ValidMovesWithTag returns the valid moves that have the given tag in
+// the same order as ValidMoves.  Only moves to the squares of the
+// opponent's pieces are generated for Capture and to the en passant
+// square for EnPassant so those are faster than filtering ValidMoves.
+// En passant captures are tagged EnPassant rather than Capture.
+func (pos *Position) ValidMovesWithTag(tag MoveTag) []*Move {
+	moves := []*Move{}
+	if cached := pos.cachedMoves(); cached != nil {
+		for _, m := range cached {
+			if m.HasTag(tag) {
+				moves = append(moves, m)
+			}
+		}
+		return moves
+	}
+	add := func(m Move) bool {
+		if m.HasTag(tag) {
+			moves = append(moves, &m)
+		}
+		return true
+	}
+	switch tag {
+	case Capture:
+		bbTargets := pos.board.blackSqs
+		if pos.turn == Black {
+			bbTargets = pos.board.whiteSqs
+		}
+		eachStandardMove(pos, bbTargets, add)
+	case EnPassant:
+		if pos.enPassantSquare != NoSquare {
+			eachStandardMove(pos, bbForSquare(pos.enPassantSquare), add)
+		}
+	case KingSideCastle, QueenSideCastle:
+		for _, m := range castleMoves(pos) {
+			add(*m)
+		}
+	case Drop:
+		eachDropMove(pos, add)
+	default:
+		pos.EachMove(add)
+	}
+	return moves
+}
+
+// IsLegal returns true if the move is valid in the position.  Only the
+// moving piece's moves are considered so it is faster than searching
+// ValidMoves for a single move.  Tags are ignored and false is returned
+// for moves that are impossible such as moves from an empty square.
+func (pos *Position) IsLegal(m *Move) bool {
+	if m == nil {
+		return false
+	}
+	if moves := pos.cachedMoves(); moves != nil {
+		return moveSlice(moves).find(m) != nil
+	}
+	return isLegalMove(pos, m)
+}
+
+// The errors wrapped by the errors returned by MoveError.
+var (
+	// ErrInvalidSquare is returned for moves to or from a square that
+	// isn't on the board.
+	ErrInvalidSquare = errors.New("chess: invalid square")
+	// ErrNoPieceAtSource is returned for moves from an empty square.
+	ErrNoPieceAtSource = errors.New("chess: no piece at the origin square")
+	// ErrWrongColor is returned for moves of a piece of the color that
+	// isn't to move.
+	ErrWrongColor = errors.New("chess: piece of the wrong color")
+	// ErrOwnPieceAtDestination is returned for moves onto a square
+	// occupied by a piece of the same color.
+	ErrOwnPieceAtDestination = errors.New("chess: own piece at the destination square")
+	// ErrIllegalPieceMove is returned for moves the piece can't make
+	// such as a knight moving like a bishop.
+	ErrIllegalPieceMove = errors.New("chess: piece can't move to the destination square")
+	// ErrBlockedPath is returned for moves of a sliding piece or a pawn
+	// push blocked by a piece in the way.
+	ErrBlockedPath = errors.New("chess: path to the destination square is blocked")
+	// ErrInvalidPromotion is returned for pawn moves to the last rank
+	// without a queen, rook, bishop or knight promotion and for other
+	// moves with a promotion.
+	ErrInvalidPromotion = errors.New("chess: invalid promotion")
+	// ErrIllegalCastle is returned for castling moves that aren't
+	// allowed by the castling rights or because the king is in check,
+	// would pass through an attacked square or the path isn't clear.
+	ErrIllegalCastle = errors.New("chess: illegal castle")
+	// ErrIllegalDrop is returned for Crazyhouse drops of a piece that
+	// isn't in the pocket or onto an occupied square, and for drops in
+	// other variants.
+	ErrIllegalDrop = errors.New("chess: illegal drop")
+	// ErrLeavesKingInCheck is returned for moves that would leave the
+	// moving side's king in check.
+	ErrLeavesKingInCheck = errors.New("chess: move leaves the king in check")
+)
+
+// MoveError returns nil if the move is valid in the position and
+// otherwise an error wrapping one of the Err variables above describing
+// why it isn't.  It agrees with IsLegal so tags are ignored.
+func (pos *Position) MoveError(m *Move) error {
+	if m == nil || m.s1 < A1 || m.s1 > H8 || m.s2 < A1 || m.s2 > H8 {
+		return fmt.Errorf("%w: move %v", ErrInvalidSquare, m)
+	}
+	if _, ok := legalMove(pos, m); ok {
+		return nil
+	}
+	moveErr := func(err error) error {
+		return fmt.Errorf("%w: move %s", err, m)
+	}
+	if m.drop != NoPieceType {
+		if m.s1 != m.s2 || m.promo != NoPieceType || !isLegalDrop(pos, m.drop, m.s2) {
+			return moveErr(ErrIllegalDrop)
+		}
+		return moveErr(ErrLeavesKingInCheck)
+	}
+	p := pos.board.Piece(m.s1)
+	switch {
+	case p == NoPiece:
+		return moveErr(ErrNoPieceAtSource)
+	case p.Color() != pos.turn:
+		return moveErr(ErrWrongColor)
+	case p.Type() == King && m.promo == NoPieceType && pos.isCastleAttempt(m):
+		return moveErr(ErrIllegalCastle)
+	case pos.board.Piece(m.s2).Color() == pos.turn:
+		return moveErr(ErrOwnPieceAtDestination)
+	}
+	if bbForPossibleMoves(pos, p.Type(), m.s1)&bbForSquare(m.s2) == 0 {
+		if pos.isBlockedMove(p, m) {
+			return moveErr(ErrBlockedPath)
+		}
+		return moveErr(ErrIllegalPieceMove)
+	}
+	promotes := (p == WhitePawn && m.s2.Rank() == Rank8) || (p == BlackPawn && m.s2.Rank() == Rank1)
+	if promotes != (m.promo != NoPieceType) || m.promo == King || m.promo == Pawn {
+		return moveErr(ErrInvalidPromotion)
+	}
+	return moveErr(ErrLeavesKingInCheck)
+}
+
+// isCastleAttempt returns true if the king move has the squares of a
+// castling move: two files along the back rank from the e file or, in
+// Chess960, onto one of the side's own rooks.
+func (pos *Position) isCastleAttempt(m *Move) bool {
+	r := Rank1
+	if pos.turn == Black {
+		r = Rank8
+	}
+	if m.s1.Rank() != r || m.s2.Rank() != r {
+		return false
+	}
+	if pos.chess960 {
+		return pos.board.Piece(m.s2) == NewPiece(Rook, pos.turn)
+	}
+	return m.s1.File() == FileE && (m.s2.File() == FileC || m.s2.File() == FileG)
+}
+
+// isBlockedMove returns true if the piece could make the move on an
+// otherwise empty board but another piece is in the way.
+func (pos *Position) isBlockedMove(p Piece, m *Move) bool {
+	df := int(m.s2.File()) - int(m.s1.File())
+	dr := int(m.s2.Rank()) - int(m.s1.Rank())
+	switch p.Type() {
+	case Queen:
+		return df == 0 || dr == 0 || df == dr || df == -dr
+	case Rook:
+		return df == 0 || dr == 0
+	case Bishop:
+		return df == dr || df == -dr
+	case Pawn:
+		// pushes are blocked by any piece on the squares moved over
+		forward, start := 1, Rank2
+		if p.Color() == Black {
+			forward, start = -1, Rank7
+		}
+		return df == 0 && (dr == forward || (dr == 2*forward && m.s1.Rank() == start))
+	}
+	return false
+}
+
+// ValidMovesFrom returns the valid moves for the piece on sq in the same
+// order as ValidMoves.  Pawn moves to the last rank are included once for
+// each promotion piece type as returned by PromotionMoves.  An empty slice
+// is returned if sq is empty or holds a piece of the color that isn't to
+// move.
+func (pos *Position) ValidMovesFrom(sq Square) []*Move {
+	moves := []*Move{}
+	for _, m := range pos.ValidMoves() {
+		if m.s1 == sq {
+			moves = append(moves, m)
+		}
 	}
-	pos.validMoves = engine{}.CalcMoves(pos, false)
-	return append([]*Move(nil), pos.validMoves...)
+	return moves
+}
+
+// IsAttacked returns true if sq is attacked by any piece of the given
+// color.  Pawns only attack diagonally and sliding pieces are blocked by
+// pieces in between.  The square doesn't need to be occupied.
+func (pos *Position) IsAttacked(sq Square, by Color) bool {
+	return attackersBB(pos.board, sq, by) != 0
+}
+
+// Attackers returns the squares of the pieces of the given color that
+// attack sq in square order.
+func (pos *Position) Attackers(sq Square, by Color) []Square {
+	return attackersBB(pos.board, sq, by).squares()
+}
+
+// ControlMap returns the number of pieces of the given color attacking
+// each square with the same rules as Attackers.  Only direct attacks are
+// counted so a piece behind another piece on the same line doesn't add
+// to the count.  Squares that aren't attacked aren't in the map.
+func (pos *Position) ControlMap(c Color) map[Square]int {
+	m := map[Square]int{}
+	for sq := A1; sq <= H8; sq++ {
+		if n := len(attackersBB(pos.board, sq, c).squares()); n > 0 {
+			m[sq] = n
+		}
+	}
+	return m
+}
+
+// Pieces returns the squares holding the piece of the given color and
+// type in square order.  The squares are read from the position's
+// bitboards so no squares without the piece are visited.
+func (pos *Position) Pieces(c Color, pt PieceType) []Square {
+	return pos.board.bbForPiece(NewPiece(pt, c)).squares()
+}
+
+// Bitboard returns a bitboard of the squares holding the piece of the
+// given color and type.  Bit i is set when Square(i) holds the piece so
+// A1 is bit 0, H1 is bit 7 and H8 is bit 63.
+func (pos *Position) Bitboard(c Color, pt PieceType) uint64 {
+	return uint64(pos.board.bbForPiece(NewPiece(pt, c)).Reverse())
+}
+
+// OccupiedBitboard returns a bitboard of the occupied squares using the
+// same square to bit mapping as Bitboard.
+func (pos *Position) OccupiedBitboard() uint64 {
+	return uint64((^pos.board.emptySqs).Reverse())
+}
+
+// InCheck returns true if the king of the side to move is attacked.
+// Unlike the Check tag, which marks moves that give check, it describes
+// the position itself such as one loaded from a FEN.
+func (pos *Position) InCheck() bool {
+	return pos.inCheck
+}
+
+// IsDoubleCheck returns true if the king of the side to move is attacked
+// by two pieces.  Blocking or capturing can only stop one of the checks
+// so ValidMoves returns king moves alone in a double check.
+func (pos *Position) IsDoubleCheck() bool {
+	if !pos.inCheck {
+		return false
+	}
+	return len(pos.Checkers(pos.turn)) > 1
+}
+
+// A CheckType is the kind of check a move gives.
+type CheckType uint8
+
+const (
+	// NoCheck is the CheckType of moves that don't give check.
+	NoCheck CheckType = iota
+	// DirectCheck is the CheckType of moves where the moved piece gives
+	// check, including the rook of a castling move.
+	DirectCheck
+	// DiscoveredCheck is the CheckType of moves that uncover a check by
+	// another piece.
+	DiscoveredCheck
+	// DoubleCheck is the CheckType of moves that give check with two
+	// pieces at once.
+	DoubleCheck
+)
+
+var checkTypeNames = []string{"NoCheck", "DirectCheck", "DiscoveredCheck", "DoubleCheck"}
+
+// String implements the fmt.Stringer interface.
+func (ct CheckType) String() string {
+	if int(ct) >= len(checkTypeNames) {
+		return fmt.Sprintf("CheckType(%d)", ct)
+	}
+	return checkTypeNames[ct]
+}
+
+// CheckType returns the kind of check the move gives in the position,
+// telling apart the direct and discovered checks that are both tagged
+// Check.  NoCheck is returned for moves that don't give check and for
+// moves that aren't valid.
+func (pos *Position) CheckType(m *Move) CheckType {
+	valid, ok := legalMove(pos, m)
+	if !ok || !valid.HasTag(Check) {
+		return NoCheck
+	}
+	cp := pos.copy()
+	cp.update(&valid)
+	checkers := cp.Checkers(cp.turn)
+	if len(checkers) > 1 {
+		return DoubleCheck
+	}
+	moved := valid.s2
+	if valid.HasTag(KingSideCastle) {
+		moved = NewSquare(FileF, valid.s1.Rank())
+	} else if valid.HasTag(QueenSideCastle) {
+		moved = NewSquare(FileD, valid.s1.Rank())
+	}
+	if len(checkers) == 1 && checkers[0] != moved {
+		return DiscoveredCheck
+	}
+	return DirectCheck
+}
+
+// Checkers returns the squares of the pieces giving check to the king of
+// the given color in square order.  Two squares are returned for a double
+// check in which case only king moves are valid.
+func (pos *Position) Checkers(c Color) []Square {
+	ksq := pos.board.kingSquare(c)
+	if ksq == NoSquare {
+		return []Square{}
+	}
+	return pos.Attackers(ksq, c.Other())
+}
+
+// PinnedPieces returns the pieces of the given color that are pinned to
+// their king mapped to the square of the enemy piece pinning them.
+func (pos *Position) PinnedPieces(c Color) map[Square]Square {
+	pins := map[Square]Square{}
+	ksq := pos.board.kingSquare(c)
+	if ksq == NoSquare {
+		return pins
+	}
+	dirs := []struct {
+		df, dr  int
+		sliders []PieceType
+	}{
+		{0, 1, []PieceType{Rook, Queen}},
+		{0, -1, []PieceType{Rook, Queen}},
+		{1, 0, []PieceType{Rook, Queen}},
+		{-1, 0, []PieceType{Rook, Queen}},
+		{1, 1, []PieceType{Bishop, Queen}},
+		{1, -1, []PieceType{Bishop, Queen}},
+		{-1, 1, []PieceType{Bishop, Queen}},
+		{-1, -1, []PieceType{Bishop, Queen}},
+	}
+	for _, dir := range dirs {
+		pinned := NoSquare
+		for sq, ok := ksq.Offset(dir.df, dir.dr); ok; sq, ok = sq.Offset(dir.df, dir.dr) {
+			p := pos.board.Piece(sq)
+			if p == NoPiece {
+				continue
+			}
+			if p.Color() == c {
+				if pinned != NoSquare {
+					break
+				}
+				pinned = sq
+				continue
+			}
+			if pinned != NoSquare && (p.Type() == dir.sliders[0] || p.Type() == dir.sliders[1]) {
+				pins[pinned] = sq
+			}
+			break
+		}
+	}
+	return pins
+}
+
+// hasSufficientMaterial returns false if neither side can checkmate.
+// Crazyhouse positions always have sufficient material since captured
+// pieces can be dropped as do King of the Hill positions since a lone
+// king can still reach the center.
+func (pos *Position) hasSufficientMaterial() bool {
+	return pos.variant == Crazyhouse || pos.variant == KingOfTheHill || pos.board.hasSufficientMaterial()
+}
+
+// InsufficientMaterial returns true if neither side has the material to
+// checkmate by any series of legal moves: king against king, king and a
+// minor piece against king, or kings with any number of bishops that are
+// all on squares of the same color.  It is the same check Status and
+// Game use for the InsufficientMaterial draw.
+func (pos *Position) InsufficientMaterial() bool {
+	return !pos.hasSufficientMaterial()
 }
 
 // Status returns the position's status as one of the outcome methods.
-// Possible returns values include Checkmate, Stalemate, and NoMethod.
+// Possible returns values include Checkmate, Stalemate, InsufficientMaterial,
+// SeventyFiveMoveRule and NoMethod.  SeventyFiveMoveRule is returned once
+// the half move clock reaches 150 unless the position is checkmate since
+// the draw is automatic.  The claimable FiftyMoveRule and repetition draws
+// depend on the game and are handled by Game.  King of the Hill positions
+// with a king on d4, e4, d5 or e5 return KingInCenter and Three-check
+// positions where a side has given three checks return ThirdCheck.
 func (pos *Position) Status() Method {
 	return engine{}.Status(pos)
 }
 
-// Board returns the position's board.
+// Board returns a copy of the position's board.  Changes to the
+// returned board don't affect the position.
 func (pos *Position) Board() *Board {
-	return pos.board
+	return pos.board.copy()
 }
 
 // Turn returns the color to move next.
@@ -126,27 +709,161 @@ func (pos *Position) HalfMoveClock() int {
 	return pos.halfMoveClock
 }
 
-// EnPassantSquare returns the en-passant square.
+// FullMoveNumber returns the full move number which starts at one and
+// is incremented after each of Black's moves, as written in the FEN.
+func (pos *Position) FullMoveNumber() int {
+	return pos.moveCount
+}
+
+// EnPassantSquare returns the en-passant square, the square a pawn
+// that just advanced two squares passed over, as written in the FEN.
+// NoSquare, which is distinct from A1, is returned if the last move
+// wasn't a two square pawn advance.  The square is set even if no en
+// passant capture is possible.
 func (pos *Position) EnPassantSquare() Square {
 	return pos.enPassantSquare
 }
 
-// CastleRights returns the castling rights of the position.
+// CastleRights returns the castling rights of the position.  The
+// rights use the KQkq letters even for Chess960 positions.
 func (pos *Position) CastleRights() CastleRights {
 	return pos.castleRights
 }
 
+// WithCastleRights returns a copy of the position with the given castle
+// rights.  Rights that the board doesn't allow, because the king or rook
+// isn't on its starting square, are dropped.  For Chess960 positions a
+// right the position didn't have uses the outermost rook on that side.
+func (pos *Position) WithCastleRights(cr CastleRights) *Position {
+	cp := pos.copy()
+	rights := ""
+	for _, c := range []Color{White, Black} {
+		for _, side := range []Side{KingSide, QueenSide} {
+			if !cr.CanCastle(c, side) {
+				continue
+			}
+			i := castleIndex(c, side)
+			if cp.chess960 && cp.castleRooks[i] == NoSquare {
+				_, rooks, err := formChess960CastleRooks(castleRightsChar(c, side), cp.board)
+				if err != nil {
+					continue
+				}
+				cp.castleRooks[i] = rooks[i]
+			}
+			ksq := NewSquare(FileE, castleRank(c))
+			if cp.chess960 {
+				ksq = cp.board.kingSquare(c)
+			}
+			if ksq.Rank() != castleRank(c) || cp.board.Piece(ksq) != NewPiece(King, c) ||
+				cp.board.Piece(cp.castleRookSquare(c, side)) != NewPiece(Rook, c) {
+				continue
+			}
+			rights += castleRightsChar(c, side)
+		}
+	}
+	if rights == "" {
+		rights = "-"
+	}
+	cp.castleRights = CastleRights(rights)
+	return cp
+}
+
+// FlipVertical returns the same position with the colors reversed.  The
+// board is flipped over the horizontal center line, the colors of the
+// pieces are swapped and it becomes the other side's turn.  The castle
+// rights and en passant square are swapped and flipped to match, as are
+// Crazyhouse pockets and Three-check check counts, while the half move
+// clock and move count are kept.
+func (pos *Position) FlipVertical() *Position {
+	flip := func(sq Square) Square {
+		return NewSquare(sq.File(), Rank(7-sq.Rank()))
+	}
+	m := map[Square]Piece{}
+	for sq, p := range pos.board.SquareMap() {
+		m[flip(sq)] = NewPiece(p.Type(), p.Color().Other())
+	}
+	cp := pos.copy()
+	cp.board = NewBoard(m)
+	cp.turn = pos.turn.Other()
+	rights := ""
+	for _, c := range []Color{White, Black} {
+		for _, side := range []Side{KingSide, QueenSide} {
+			if pos.castleRights.CanCastle(c.Other(), side) {
+				rights += castleRightsChar(c, side)
+			}
+			rook := pos.castleRooks[castleIndex(c.Other(), side)]
+			if pos.chess960 && rook != NoSquare {
+				rook = flip(rook)
+			}
+			cp.castleRooks[castleIndex(c, side)] = rook
+		}
+	}
+	if rights == "" {
+		rights = "-"
+	}
+	cp.castleRights = CastleRights(rights)
+	if pos.enPassantSquare != NoSquare {
+		cp.enPassantSquare = flip(pos.enPassantSquare)
+	}
+	cp.pockets[White], cp.pockets[Black] = pos.pockets[Black], pos.pockets[White]
+	cp.checks[White], cp.checks[Black] = pos.checks[Black], pos.checks[White]
+	cp.promoted = 0
+	for _, sq := range pos.promoted.squares() {
+		cp.promoted |= bbForSquare(flip(sq))
+	}
+	return cp
+}
+
+// Mirror returns the position with the board mirrored over the vertical
+// center line so the a and h files are swapped.  The en passant square
+// is mirrored to match.  Castle rights are dropped for standard positions
+// since the kings are no longer on the e file.  Chess960 positions keep
+// their castle rights with each castling rook mirrored, which makes a
+// king side right a queen side right and the other way around.
+func (pos *Position) Mirror() *Position {
+	mirror := func(sq Square) Square {
+		return NewSquare(File(7-sq.File()), sq.Rank())
+	}
+	cp := pos.copy()
+	cp.board = pos.board.Flip(LeftRight)
+	cp.castleRights = "-"
+	if pos.chess960 {
+		for _, c := range []Color{White, Black} {
+			for _, side := range []Side{KingSide, QueenSide} {
+				other := KingSide
+				if side == KingSide {
+					other = QueenSide
+				}
+				rook := pos.castleRooks[castleIndex(c, other)]
+				if rook != NoSquare {
+					rook = mirror(rook)
+				}
+				cp.castleRooks[castleIndex(c, side)] = rook
+			}
+		}
+		cp.castleRights = chess960CastleRights(cp.castleRooks)
+	}
+	if pos.enPassantSquare != NoSquare {
+		cp.enPassantSquare = mirror(pos.enPassantSquare)
+	}
+	cp.promoted = 0
+	for _, sq := range pos.promoted.squares() {
+		cp.promoted |= bbForSquare(mirror(sq))
+	}
+	return cp
+}
+
 // String implements the fmt.Stringer interface and returns a
 // string with the FEN format: rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1
+// Chess960 positions use Shredder-FEN castling rights with the files of
+// the castling rooks such as HAha.
 func (pos *Position) String() string {
-	b := pos.board.String()
-	t := pos.turn.String()
-	c := pos.castleRights.String()
-	sq := "-"
-	if pos.enPassantSquare != NoSquare {
-		sq = pos.enPassantSquare.String()
-	}
-	return fmt.Sprintf("%s %s %s %s %d %d", b, t, c, sq, pos.halfMoveClock, pos.moveCount)
+	return fmt.Sprintf("%s %d %d", pos.EPD(), pos.halfMoveClock, pos.moveCount)
+}
+
+// FEN returns the position in FEN notation.  It is equivalent to String.
+func (pos *Position) FEN() string {
+	return pos.String()
 }
 
 // Hash returns a unique hash of the position
@@ -175,6 +892,12 @@ func (pos *Position) UnmarshalText(text []byte) error {
 	pos.halfMoveClock = cp.halfMoveClock
 	pos.moveCount = cp.moveCount
 	pos.inCheck = isInCheck(cp)
+	pos.chess960 = cp.chess960
+	pos.castleRooks = cp.castleRooks
+	pos.variant = cp.variant
+	pos.pockets = cp.pockets
+	pos.promoted = cp.promoted
+	pos.validMoves = atomic.Value{}
 	return nil
 }
 
@@ -255,6 +978,7 @@ func (pos *Position) UnmarshalBinary(data []byte) error {
 		return err
 	}
 	pos.board = board
+	pos.validMoves = atomic.Value{}
 	buf := bytes.NewBuffer(data[96:])
 	halfMove := uint8(pos.halfMoveClock)
 	if err := binary.Read(buf, binary.BigEndian, &halfMove); err != nil {
@@ -309,23 +1033,26 @@ func (pos *Position) copy() *Position {
 		halfMoveClock:   pos.halfMoveClock,
 		moveCount:       pos.moveCount,
 		inCheck:         pos.inCheck,
+		chess960:        pos.chess960,
+		castleRooks:     pos.castleRooks,
+		variant:         pos.variant,
+		pockets:         pos.pockets,
+		promoted:        pos.promoted,
+		checks:          pos.checks,
 	}
 }
 
 func (pos *Position) updateCastleRights(m *Move) CastleRights {
 	cr := string(pos.castleRights)
 	p := pos.board.Piece(m.s1)
-	if p == WhiteKing || m.s1 == H1 || m.s2 == H1 {
-		cr = strings.Replace(cr, "K", "", -1)
-	}
-	if p == WhiteKing || m.s1 == A1 || m.s2 == A1 {
-		cr = strings.Replace(cr, "Q", "", -1)
-	}
-	if p == BlackKing || m.s1 == H8 || m.s2 == H8 {
-		cr = strings.Replace(cr, "k", "", -1)
-	}
-	if p == BlackKing || m.s1 == A8 || m.s2 == A8 {
-		cr = strings.Replace(cr, "q", "", -1)
+	for _, c := range []Color{White, Black} {
+		king := NewPiece(King, c)
+		for _, side := range []Side{KingSide, QueenSide} {
+			rook := pos.castleRookSquare(c, side)
+			if p == king || m.s1 == rook || m.s2 == rook {
+				cr = strings.Replace(cr, castleRightsChar(c, side), "", -1)
+			}
+		}
 	}
 	if cr == "" {
 		cr = "-"
@@ -333,6 +1060,68 @@ func (pos *Position) updateCastleRights(m *Move) CastleRights {
 	return CastleRights(cr)
 }
 
+// castleRookSquare returns the starting square of the rook that the
+// color castles with on the given side.
+func (pos *Position) castleRookSquare(c Color, side Side) Square {
+	if pos.chess960 {
+		return pos.castleRooks[castleIndex(c, side)]
+	}
+	switch {
+	case c == White && side == KingSide:
+		return H1
+	case c == White && side == QueenSide:
+		return A1
+	case c == Black && side == KingSide:
+		return H8
+	}
+	return A8
+}
+
+// castleRightsFEN returns the castling rights for the FEN which uses the
+// files of the castling rooks for Chess960 positions.
+func (pos *Position) castleRightsFEN() string {
+	if !pos.chess960 || pos.castleRights == "-" {
+		return pos.castleRights.String()
+	}
+	s := ""
+	for _, c := range []Color{White, Black} {
+		for _, side := range []Side{KingSide, QueenSide} {
+			if !pos.castleRights.CanCastle(c, side) {
+				continue
+			}
+			f := pos.castleRookSquare(c, side).File().String()
+			if c == White {
+				f = strings.ToUpper(f)
+			}
+			s += f
+		}
+	}
+	return s
+}
+
+func castleIndex(c Color, side Side) int {
+	i := 0
+	if c == Black {
+		i = 2
+	}
+	if side == QueenSide {
+		i++
+	}
+	return i
+}
+
+func castleRightsChar(c Color, side Side) string {
+	switch {
+	case c == White && side == KingSide:
+		return "K"
+	case c == White:
+		return "Q"
+	case side == KingSide:
+		return "k"
+	}
+	return "q"
+}
+
 func (pos *Position) updateEnPassantSquare(m *Move) Square {
 	p := pos.board.Piece(m.s1)
 	if p.Type() != Pawn {
@@ -350,9 +1139,73 @@ func (pos *Position) updateEnPassantSquare(m *Move) Square {
 	return NoSquare
 }
 
-func (pos *Position) samePosition(pos2 *Position) bool {
+// SamePosition returns true if the positions are the same for the
+// purposes of repetition.  The piece placement, turn and castling rights
+// are compared, and the en passant square is only considered when an en
+// passant capture is actually possible.  The half move clock and move
+// count are ignored.
+func (pos *Position) SamePosition(pos2 *Position) bool {
+	if pos2 == nil {
+		return false
+	}
 	return pos.board.String() == pos2.board.String() &&
 		pos.turn == pos2.turn &&
 		pos.castleRights.String() == pos2.castleRights.String() &&
-		pos.enPassantSquare == pos2.enPassantSquare
+		pos.validEnPassantSquare() == pos2.validEnPassantSquare() &&
+		pos.pockets == pos2.pockets &&
+		pos.checks == pos2.checks
+}
+
+// RepetitionKey returns a compact key that is the same for two positions
+// exactly when SamePosition is true, which makes it suitable as a map key
+// for counting repetitions.  The key is binary data rather than text: the
+// piece on each square packed into four bits followed by the turn, the
+// en passant square if an en passant capture is possible, the castling
+// rights and any pockets or checks given.
+func (pos *Position) RepetitionKey() string {
+	key := make([]byte, numOfSquaresInBoard/2, numOfSquaresInBoard/2+8)
+	for _, p := range allPieces {
+		for _, sq := range pos.board.bbForPiece(p).squares() {
+			key[sq/2] |= uint8(p) << (4 * uint(sq%2))
+		}
+	}
+	cr := pos.castleRights.String()
+	key = append(key, uint8(pos.turn), uint8(pos.validEnPassantSquare()), uint8(len(cr)))
+	key = append(key, cr...)
+	if pos.pockets != (pockets{}) {
+		key = append(key, 'p')
+		for _, c := range []Color{White, Black} {
+			key = append(key, pos.pockets[c][1:]...)
+		}
+	}
+	if pos.checks != ([3]uint8{}) {
+		key = append(key, 'c', pos.checks[White], pos.checks[Black])
+	}
+	return string(key)
+}
+
+// validEnPassantSquare returns the en passant square if the side to
+// move has a valid en passant capture and NoSquare otherwise.  Only the
+// moves of the at most two pawns that attack the square are checked so
+// hashing doesn't generate the position's moves.
+func (pos *Position) validEnPassantSquare() Square {
+	ep := pos.enPassantSquare
+	if ep == NoSquare {
+		return NoSquare
+	}
+	dr := -1
+	if pos.turn == Black {
+		dr = 1
+	}
+	pawn := NewPiece(Pawn, pos.turn)
+	for _, df := range [2]int{-1, 1} {
+		sq, ok := ep.Offset(df, dr)
+		if !ok || pos.board.Piece(sq) != pawn {
+			continue
+		}
+		if isLegalMove(pos, &Move{s1: sq, s2: ep}) {
+			return ep
+		}
+	}
+	return NoSquare
 }
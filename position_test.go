@@ -1,6 +1,10 @@
 package chess
 
 import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -23,3 +27,735 @@ func TestPositionBinary(t *testing.T) {
 		}
 	}
 }
+
+func TestPositionStatus(t *testing.T) {
+	tests := []struct {
+		fen    string
+		method Method
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", NoMethod},
+		{"rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3", Checkmate},
+		{"k7/8/1QK5/8/8/8/8/8 b - - 0 1", Stalemate},
+		{"8/2k5/8/8/8/3K4/8/8 w - - 1 1", InsufficientMaterial},
+		{"8/2k5/8/8/8/3K1B2/8/8 w - - 1 1", InsufficientMaterial},
+		{"8/2k5/8/8/8/3K1N2/8/8 w - - 1 1", InsufficientMaterial},
+		{"8/2k5/2b5/8/8/3K1B2/8/8 w - - 1 1", InsufficientMaterial},
+		{"8/2k1b3/8/8/8/3K1B2/8/8 w - - 1 1", NoMethod},
+		{"8/2kn4/8/8/8/3K1N2/8/8 w - - 1 1", NoMethod},
+	}
+	for _, test := range tests {
+		pos, err := NewPositionFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos.Status() != test.method {
+			t.Fatalf("expected %s to have status %s but got %s", test.fen, test.method, pos.Status())
+		}
+	}
+}
+
+func TestPositionBoardIsCopy(t *testing.T) {
+	pos := StartingPosition()
+	b := pos.Board()
+	if err := b.UnmarshalText([]byte("8/8/8/8/8/8/8/8")); err != nil {
+		t.Fatal(err)
+	}
+	if pos.Board().Piece(E1) != WhiteKing {
+		t.Fatal("expected changes to the returned board to not affect the position")
+	}
+	if len(pos.Board().SquareMap()) != 32 {
+		t.Fatalf("expected 32 pieces but got %d", len(pos.Board().SquareMap()))
+	}
+}
+
+func TestPositionMove(t *testing.T) {
+	tests := []struct {
+		fen      string
+		move     *Move
+		expected string
+	}{
+		// king move clears castling rights and advances the counters
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 3 10", &Move{s1: E1, s2: E2}, "r3k2r/8/8/8/8/8/4K3/R6R b kq - 4 10"},
+		// rook capture clears both sides' rights on that wing
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 10", &Move{s1: H8, s2: H1}, "r3k3/8/8/8/8/8/8/R3K2r w Qq - 0 11"},
+		// double pawn push sets the en passant target
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", &Move{s1: E2, s2: E4}, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"},
+		// en passant capture clears the target
+		{"rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3", &Move{s1: E5, s2: D6}, "rnbqkbnr/ppp1pppp/3P4/8/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 3"},
+	}
+	for _, test := range tests {
+		pos := unsafeFEN(test.fen)
+		next, err := pos.Move(test.move)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next.String() != test.expected {
+			t.Fatalf("expected position %s but got %s", test.expected, next)
+		}
+		if pos.String() != test.fen {
+			t.Fatalf("expected original position %s to be unchanged but got %s", test.fen, pos)
+		}
+	}
+}
+
+func TestPositionMoveInvalid(t *testing.T) {
+	pos := StartingPosition()
+	for _, m := range []*Move{{s1: E2, s2: E5}, {s1: E7, s2: E5}, {s1: E1, s2: G1}} {
+		if _, err := pos.Move(m); err == nil {
+			t.Fatalf("expected move %s to be invalid", m)
+		}
+	}
+	if pos.String() != StartingPosition().String() {
+		t.Fatalf("expected position to be unchanged but got %s", pos)
+	}
+}
+
+func TestPositionAttackers(t *testing.T) {
+	// the rook on a1 is blocked by the knight on c1, the bishop on h1 by the
+	// knight on f3, the queen on h4 by the pawn on g4, and the pawns don't
+	// attack the squares in front of them
+	pos := unsafeFEN("4k3/8/8/3p4/6Pq/2b2N2/4P3/R1N1K2B w - - 0 1")
+	tests := []struct {
+		sq        Square
+		by        Color
+		attackers []Square
+	}{
+		{B1, White, []Square{A1}},
+		{D1, White, []Square{E1}},
+		{E4, White, []Square{}},
+		{E3, White, []Square{}},
+		{D3, White, []Square{C1, E2}},
+		{E5, White, []Square{F3}},
+		{E4, Black, []Square{D5}},
+		{E1, Black, []Square{C3, H4}},
+		{F2, Black, []Square{H4}},
+		{D4, Black, []Square{C3}},
+		{E2, Black, []Square{}},
+	}
+	for _, test := range tests {
+		attackers := pos.Attackers(test.sq, test.by)
+		if len(attackers) != len(test.attackers) {
+			t.Fatalf("expected attackers %v of %s by %s but got %v", test.attackers, test.sq, test.by.Name(), attackers)
+		}
+		for _, sq := range test.attackers {
+			found := false
+			for _, a := range attackers {
+				found = found || a == sq
+			}
+			if !found {
+				t.Fatalf("expected %s to attack %s but got %v", sq, test.sq, attackers)
+			}
+		}
+		if pos.IsAttacked(test.sq, test.by) != (len(test.attackers) > 0) {
+			t.Fatalf("expected IsAttacked(%s, %s) to be %t", test.sq, test.by.Name(), len(test.attackers) > 0)
+		}
+	}
+}
+
+func TestPositionRandomMove(t *testing.T) {
+	pos := StartingPosition()
+	counts := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		m, ok := pos.RandomMove(rand.New(rand.NewSource(int64(i))))
+		if !ok || !pos.IsLegal(m) {
+			t.Fatalf("expected a legal random move but got %v", m)
+		}
+		counts[m.String()]++
+	}
+	if len(counts) != 20 {
+		t.Fatalf("expected all 20 moves to be chosen but got %d", len(counts))
+	}
+	for mv, n := range counts {
+		if n < 40 || n > 170 {
+			t.Fatalf("expected move %s to be chosen about 100 times but got %d", mv, n)
+		}
+	}
+	m1, _ := pos.RandomMove(rand.New(rand.NewSource(42)))
+	m2, _ := pos.RandomMove(rand.New(rand.NewSource(42)))
+	if !m1.Equal(*m2) {
+		t.Fatalf("expected the same seed to choose the same move but got %s and %s", m1, m2)
+	}
+	cached := StartingPosition()
+	cached.ValidMoves()
+	for i := 0; i < 100; i++ {
+		m1, _ := pos.RandomMove(rand.New(rand.NewSource(int64(i))))
+		m2, ok := cached.RandomMove(rand.New(rand.NewSource(int64(i))))
+		if !ok || !m1.Equal(*m2) {
+			t.Fatalf("expected seed %d to choose %s from the cached moves but got %v", i, m1, m2)
+		}
+	}
+	if m, ok := unsafeFEN("k7/2Q5/1K6/8/8/8/8/8 b - - 0 1").RandomMove(rand.New(rand.NewSource(1))); ok || m != nil {
+		t.Fatal("expected no random move without valid moves")
+	}
+}
+
+func TestPositionNumLegalMoves(t *testing.T) {
+	for _, fen := range []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3",
+		"k7/2Q5/1K6/8/8/8/8/8 b - - 0 1",
+	} {
+		pos := unsafeFEN(fen)
+		pos.inCheck = isInCheck(pos)
+		n := pos.NumLegalMoves()
+		if expected := len(pos.ValidMoves()); n != expected {
+			t.Fatalf("expected %d legal moves for %s but got %d", expected, fen, n)
+		}
+		if cached := pos.NumLegalMoves(); cached != n {
+			t.Fatalf("expected %d cached legal moves for %s but got %d", n, fen, cached)
+		}
+	}
+	pos := unsafeFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if allocs := testing.AllocsPerRun(10, func() { pos.NumLegalMoves() }); allocs != 0 {
+		t.Fatalf("expected counting legal moves not to allocate but got %v allocations", allocs)
+	}
+}
+
+func TestPositionApplyMoves(t *testing.T) {
+	g := NewGame()
+	for _, m := range []string{"e4", "e5", "Nf3", "Nc6", "Bc4", "Bc5", "O-O", "Nf6", "d4", "exd4", "e5", "d5", "exf6", "dxc4"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the moves don't need tags
+	moves := []*Move{}
+	for _, m := range g.Moves() {
+		moves = append(moves, &Move{s1: m.s1, s2: m.s2, promo: m.promo})
+	}
+	start := StartingPosition()
+	pos, err := start.ApplyMoves(moves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.String() != g.Position().String() {
+		t.Fatalf("expected %s but got %s", g.Position(), pos)
+	}
+	if start.String() != startFEN {
+		t.Fatalf("expected the starting position to be unchanged but got %s", start)
+	}
+	if allocs := testing.AllocsPerRun(10, func() { start.ApplyMoves(moves) }); allocs >= float64(len(moves)) {
+		t.Fatalf("expected fewer allocations than moves but got %v", allocs)
+	}
+	moves[2] = &Move{s1: E1, s2: G1}
+	if _, err := start.ApplyMoves(moves); err == nil || !strings.Contains(err.Error(), "move 2 ") {
+		t.Fatalf("expected an error naming move 2 but got %v", err)
+	}
+}
+
+func TestPositionForcedMoves(t *testing.T) {
+	tests := []struct {
+		fen    string
+		forced string
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", ""},
+		// the king's only escape from the rook
+		{"k7/8/1K6/8/8/8/8/R7 b - - 0 1", "a8b8"},
+		// checkmate has no moves at all
+		{"k7/1Q6/1K6/8/8/8/8/8 b - - 0 1", ""},
+	}
+	for _, test := range tests {
+		pos := unsafeFEN(test.fen)
+		pos.inCheck = isInCheck(pos)
+		moves := pos.ForcedMoves()
+		if test.forced == "" {
+			if len(moves) != 0 {
+				t.Fatalf("expected no forced moves for %s but got %v", test.fen, moves)
+			}
+			continue
+		}
+		if len(moves) != 1 || moves[0].String() != test.forced || !moves[0].Equal(*pos.ValidMoves()[0]) {
+			t.Fatalf("expected forced move %s for %s but got %v", test.forced, test.fen, moves)
+		}
+	}
+}
+
+func TestPositionControlMap(t *testing.T) {
+	m := StartingPosition().ControlMap(White)
+	expected := map[Square]int{F3: 3, C3: 3, D3: 2, E3: 2, A3: 2, H3: 2, B3: 2, G3: 2, D2: 4, E2: 4, B1: 1, G1: 1}
+	for sq, n := range expected {
+		if m[sq] != n {
+			t.Fatalf("expected %s to be attacked by %d white pieces but got %d", sq, n, m[sq])
+		}
+	}
+	if _, ok := m[E4]; ok {
+		t.Fatal("expected e4 not to be in the control map")
+	}
+	// the rook on a1 x-rays through the rook on a2 which isn't counted
+	m = unsafeFEN("4k3/8/8/8/8/8/R7/R3K3 w - - 0 1").ControlMap(White)
+	if m[A3] != 1 || m[A2] != 1 || m[B2] != 1 || m[D2] != 2 {
+		t.Fatalf("expected direct attacks only but got %v", m)
+	}
+	for sq, n := range m {
+		if n != len(unsafeFEN("4k3/8/8/8/8/8/R7/R3K3 w - - 0 1").Attackers(sq, White)) {
+			t.Fatalf("expected the count for %s to match its attackers", sq)
+		}
+	}
+}
+
+func TestPositionPinnedPieces(t *testing.T) {
+	// the e2 knight is pinned by the e8 rook, the d2 pawn by the a5 bishop,
+	// the f2 pawn by the g3 queen in front of the h4 bishop and the c1 bishop
+	// isn't pinned since the b1 bishop can't move along the rank
+	pos := unsafeFEN("4r1k1/8/8/b7/7b/6q1/3PNP2/1bB1K3 w - - 0 1")
+	pins := pos.PinnedPieces(White)
+	expected := map[Square]Square{E2: E8, D2: A5, F2: G3}
+	if len(pins) != len(expected) {
+		t.Fatalf("expected pins %v but got %v", expected, pins)
+	}
+	for sq, pinner := range expected {
+		if pins[sq] != pinner {
+			t.Fatalf("expected %s to be pinned by %s but got %v", sq, pinner, pins)
+		}
+	}
+	if len(pos.PinnedPieces(Black)) != 0 {
+		t.Fatalf("expected no black pins but got %v", pos.PinnedPieces(Black))
+	}
+}
+
+func TestPositionCheckers(t *testing.T) {
+	tests := []struct {
+		fen      string
+		c        Color
+		checkers []Square
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", White, []Square{}},
+		{"rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3", White, []Square{H4}},
+		// double check from a discovered attack
+		{"4r1k1/8/8/8/8/3n4/8/4K3 w - - 0 1", White, []Square{D3, E8}},
+	}
+	for _, test := range tests {
+		pos := unsafeFEN(test.fen)
+		checkers := pos.Checkers(test.c)
+		if len(checkers) != len(test.checkers) {
+			t.Fatalf("expected checkers %v for %s but got %v", test.checkers, test.fen, checkers)
+		}
+		for i := range checkers {
+			if checkers[i] != test.checkers[i] {
+				t.Fatalf("expected checkers %v for %s but got %v", test.checkers, test.fen, checkers)
+			}
+		}
+	}
+}
+
+func TestPositionInCheck(t *testing.T) {
+	tests := []struct {
+		fen     string
+		inCheck bool
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", false},
+		{"rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3", true},
+		// only the side to move can be in check
+		{"4k3/8/8/8/8/8/8/4K2R w K - 0 1", false},
+	}
+	for _, test := range tests {
+		pos, err := NewPositionFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos.InCheck() != test.inCheck {
+			t.Fatalf("expected in check to be %t for %s", test.inCheck, test.fen)
+		}
+	}
+	next, err := unsafeFEN("4k3/8/8/8/8/8/8/4K2R w K - 0 1").Move(&Move{s1: H1, s2: H8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !next.InCheck() {
+		t.Fatal("expected black to be in check after Rh8")
+	}
+}
+
+func TestPositionIsDoubleCheck(t *testing.T) {
+	tests := []struct {
+		fen         string
+		doubleCheck bool
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", false},
+		// the rook on a5 could take the bishop and the knight could block either check
+		{"3nk3/8/8/rB6/8/8/8/4R1K1 b - - 0 1", true},
+		{"3nk3/8/8/rB6/8/8/8/5RK1 b - - 0 1", false},
+		{"3nk3/8/8/r7/8/8/8/4R1K1 b - - 0 1", false},
+	}
+	for _, test := range tests {
+		pos, err := NewPositionFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos.IsDoubleCheck() != test.doubleCheck {
+			t.Fatalf("expected double check to be %t for %s", test.doubleCheck, test.fen)
+		}
+		moves := pos.ValidMoves()
+		if len(moves) == 0 {
+			t.Fatalf("expected valid moves for %s", test.fen)
+		}
+		kingOnly := true
+		for _, m := range moves {
+			if pos.Board().Piece(m.S1()).Type() != King {
+				kingOnly = false
+			}
+		}
+		if test.doubleCheck && !kingOnly {
+			t.Fatalf("expected only king moves in double check for %s but got %v", test.fen, moves)
+		}
+		if pos.InCheck() && !test.doubleCheck && kingOnly {
+			t.Fatalf("expected blocks or captures of a single check for %s", test.fen)
+		}
+	}
+}
+
+func TestPositionCheckType(t *testing.T) {
+	tests := []struct {
+		fen  string
+		move *Move
+		ct   CheckType
+	}{
+		{"4k3/8/8/8/8/8/8/R3K3 w Q - 0 1", &Move{s1: A1, s2: A8}, DirectCheck},
+		{"4k3/8/8/8/8/8/8/R3K3 w Q - 0 1", &Move{s1: A1, s2: A7}, NoCheck},
+		// the rook lands on d1 in front of the king
+		{"3k4/8/8/8/8/8/8/R3K3 w Q - 0 1", &Move{s1: E1, s2: C1}, DirectCheck},
+		// the bishop moving off the e file uncovers the rook
+		{"4k3/8/8/8/8/4B3/8/4RK2 w - - 0 1", &Move{s1: E3, s2: A7}, DiscoveredCheck},
+		{"4k3/8/8/8/4N3/8/8/4RK2 w - - 0 1", &Move{s1: E4, s2: F6}, DoubleCheck},
+		{"4k3/8/8/8/8/4N3/8/4RK2 w - - 0 1", &Move{s1: E3, s2: D5}, DiscoveredCheck},
+		// en passant can uncover a check along the rank
+		{"8/8/8/k2pP2R/8/8/8/7K w - d6 0 1", &Move{s1: E5, s2: D6}, DiscoveredCheck},
+		// moves that aren't valid give no check
+		{"4k3/8/8/8/8/8/8/R3K3 w Q - 0 1", &Move{s1: A1, s2: B8}, NoCheck},
+	}
+	for _, test := range tests {
+		pos, err := NewPositionFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ct := pos.CheckType(test.move); ct != test.ct {
+			t.Fatalf("expected %s for %s in %s but got %s", test.ct, test.move, test.fen, ct)
+		}
+	}
+}
+
+func TestPositionInsufficientMaterial(t *testing.T) {
+	tests := []struct {
+		fen          string
+		insufficient bool
+	}{
+		{"8/2k5/8/8/8/3K4/8/8 w - - 0 1", true},
+		{"8/2k5/8/8/8/3K1N2/8/8 w - - 0 1", true},
+		{"8/2k5/8/8/8/3K1B2/8/8 w - - 0 1", true},
+		// bishops on light squares
+		{"8/2k5/2b5/8/8/3K1B2/8/8 w - - 0 1", true},
+		{"4b3/2k5/2b5/8/8/3K1B2/8/8 w - - 0 1", true},
+		// bishops on both colors can mate
+		{"8/2k5/1b6/8/8/3K1B2/8/8 w - - 0 1", false},
+		{"8/2k5/8/8/8/3KBB2/8/8 w - - 0 1", false},
+		{"8/2k5/2n5/8/8/3K1B2/8/8 w - - 0 1", false},
+		{"8/2k5/8/8/8/3K1NN1/8/8 w - - 0 1", false},
+		{"8/2k5/8/8/8/3K1P2/8/8 w - - 0 1", false},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", false},
+	}
+	for _, test := range tests {
+		pos, err := NewPositionFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos.InsufficientMaterial() != test.insufficient {
+			t.Fatalf("expected insufficient material to be %t for %s", test.insufficient, test.fen)
+		}
+		if (pos.Status() == InsufficientMaterial) != test.insufficient {
+			t.Fatalf("expected the status of %s to agree but got %s", test.fen, pos.Status())
+		}
+	}
+	pos, err := NewVariantPosition(Crazyhouse, "8/2k5/8/8/8/3K4/8/8[] w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.InsufficientMaterial() {
+		t.Fatal("expected crazyhouse positions to have sufficient material")
+	}
+}
+
+func TestPositionSamePosition(t *testing.T) {
+	tests := []struct {
+		fen1 string
+		fen2 string
+		same bool
+	}{
+		// move counters are ignored
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 4 3", true},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1", false},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w Kkq - 0 1", false},
+		// no black pawn can capture en passant on e3
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1", true},
+		// the d4 pawn can capture en passant on e3
+		{"rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 3", "rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 3", false},
+	}
+	for _, test := range tests {
+		if unsafeFEN(test.fen1).SamePosition(unsafeFEN(test.fen2)) != test.same {
+			t.Fatalf("expected %s and %s same position to be %t", test.fen1, test.fen2, test.same)
+		}
+		if (unsafeFEN(test.fen1).RepetitionKey() == unsafeFEN(test.fen2).RepetitionKey()) != test.same {
+			t.Fatalf("expected %s and %s same repetition key to be %t", test.fen1, test.fen2, test.same)
+		}
+	}
+	if StartingPosition().SamePosition(nil) {
+		t.Fatal("expected a position to not be the same as nil")
+	}
+}
+
+func TestPositionRepetitionKey(t *testing.T) {
+	g := NewGame()
+	counts := map[string]int{StartingPosition().RepetitionKey(): 1}
+	for _, m := range []string{"Nf3", "Nf6", "Ng1", "Ng8", "Nf3", "Nf6", "Ng1", "Ng8"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+		counts[g.Position().RepetitionKey()]++
+	}
+	if len(counts) != 4 || counts[StartingPosition().RepetitionKey()] != 3 {
+		t.Fatalf("expected 4 positions with the start repeated 3 times but got %v", counts)
+	}
+	if n := len(StartingPosition().RepetitionKey()); n > 40 {
+		t.Fatalf("expected a compact key but got %d bytes", n)
+	}
+	// pockets are part of the key
+	a, err := NewVariantPosition(Crazyhouse, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewVariantPosition(Crazyhouse, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[Pp] w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.RepetitionKey() == b.RepetitionKey() || a.RepetitionKey() != StartingPosition().RepetitionKey() {
+		t.Fatal("expected only the position with full pockets to have a different key")
+	}
+}
+
+func TestPositionEnPassantSquare(t *testing.T) {
+	pos := StartingPosition()
+	if pos.EnPassantSquare() != NoSquare || NoSquare == A1 {
+		t.Fatalf("expected no en passant square but got %d", pos.EnPassantSquare())
+	}
+	pos = pos.Update(&Move{s1: E2, s2: E4})
+	if pos.EnPassantSquare() != E3 {
+		t.Fatalf("expected en passant square e3 but got %s", pos.EnPassantSquare())
+	}
+	pos = pos.Update(&Move{s1: G8, s2: F6})
+	if pos.EnPassantSquare() != NoSquare {
+		t.Fatalf("expected no en passant square but got %d", pos.EnPassantSquare())
+	}
+}
+
+func TestPositionMoveCounters(t *testing.T) {
+	pos := unsafeFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 3 7")
+	tests := []struct {
+		move     *Move
+		halfMove int
+		fullMove int
+	}{
+		{&Move{s1: G1, s2: F3}, 4, 7},
+		{&Move{s1: B8, s2: C6}, 5, 8},
+		{&Move{s1: E2, s2: E4}, 0, 8},
+		{&Move{s1: G8, s2: F6}, 1, 9},
+	}
+	for _, test := range tests {
+		pos = pos.Update(test.move)
+		if pos.HalfMoveClock() != test.halfMove || pos.FullMoveNumber() != test.fullMove {
+			t.Fatalf("expected counters %d %d after %s but got %d %d", test.halfMove, test.fullMove, test.move, pos.HalfMoveClock(), pos.FullMoveNumber())
+		}
+		if !strings.HasSuffix(pos.FEN(), fmt.Sprintf(" %d %d", test.halfMove, test.fullMove)) {
+			t.Fatalf("expected fen %s to end with the counters", pos.FEN())
+		}
+	}
+}
+
+func TestPositionWithCastleRights(t *testing.T) {
+	tests := []struct {
+		fen      string
+		cr       CastleRights
+		expected string
+	}{
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "Kq", "r3k2r/8/8/8/8/8/8/R3K2R w Kq - 0 1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w - - 0 1", "qkQK", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "-", "r3k2r/8/8/8/8/8/8/R3K2R w - - 0 1"},
+		// rights without a king or rook on its starting square are dropped
+		{"r3k3/8/8/8/8/8/8/R4K1R w - - 0 1", "KQkq", "r3k3/8/8/8/8/8/8/R4K1R w q - 0 1"},
+		// chess960 rights use the outermost rook
+		{"1r3kr1/8/8/8/8/8/8/1R3KR1 w G - 0 1", "KQkq", "1r3kr1/8/8/8/8/8/8/1R3KR1 w GBgb - 0 1"},
+	}
+	for _, test := range tests {
+		pos := unsafeFEN(test.fen)
+		cp := pos.WithCastleRights(test.cr)
+		if cp.String() != test.expected {
+			t.Fatalf("expected position %s but got %s", test.expected, cp)
+		}
+		if pos.String() != test.fen {
+			t.Fatalf("expected original position %s to be unchanged but got %s", test.fen, pos)
+		}
+	}
+	pos := unsafeFEN("r3k2r/8/8/8/8/8/8/R3K2R w - - 0 1")
+	pos.ValidMoves()
+	cp := pos.WithCastleRights("KQ")
+	if !cp.IsLegal(&Move{s1: E1, s2: G1}) || pos.IsLegal(&Move{s1: E1, s2: G1}) {
+		t.Fatal("expected castling to only be legal in the copy with castle rights")
+	}
+}
+
+func TestPositionFlipVertical(t *testing.T) {
+	tests := []struct {
+		fen     string
+		flipped string
+	}{
+		{"rnbqkbnr/pppp1ppp/8/8/3pP3/8/PPP2PPP/RNBQKBNR b Kq e3 0 3", "rnbqkbnr/ppp2ppp/8/3Pp3/8/8/PPPP1PPP/RNBQKBNR w Qk e6 0 3"},
+		{"4k3/8/8/8/8/8/8/R3K2R w KQ - 0 1", "r3k2r/8/8/8/8/8/8/4K3 b kq - 0 1"},
+		{"1r4kr/8/8/8/8/8/8/1R4KR w HBhb - 0 1", "1r4kr/8/8/8/8/8/8/1R4KR b HBhb - 0 1"},
+	}
+	for _, test := range tests {
+		pos := unsafeFEN(test.fen)
+		flipped := pos.FlipVertical()
+		if flipped.String() != test.flipped {
+			t.Fatalf("expected %s flipped to be %s but got %s", test.fen, test.flipped, flipped)
+		}
+		if flipped.MaterialBalance() != -pos.MaterialBalance() || len(flipped.ValidMoves()) != len(pos.ValidMoves()) {
+			t.Fatalf("expected %s flipped to have the opposite material and the same number of moves", test.fen)
+		}
+		if back := flipped.FlipVertical(); back.String() != pos.String() {
+			t.Fatalf("expected %s flipped twice to be unchanged but got %s", test.fen, back)
+		}
+	}
+	// the checks given in Three-check belong to the swapped colors
+	pos, err := NewVariantPosition(ThreeCheck, "4k3/8/8/8/8/8/8/4K2R b K - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos.checks[White] = 2
+	flipped := pos.FlipVertical()
+	if flipped.CheckCount(White) != 0 || flipped.CheckCount(Black) != 2 {
+		t.Fatalf("expected black to have given 2 checks but got %d and %d", flipped.CheckCount(White), flipped.CheckCount(Black))
+	}
+	pos.checks[White] = 3
+	if method := pos.FlipVertical().Status(); method != ThirdCheck || pos.FlipVertical().variantWinner(method) != Black {
+		t.Fatalf("expected black to win by the third check but got %s", method)
+	}
+}
+
+func TestPositionMirror(t *testing.T) {
+	tests := []struct {
+		fen      string
+		mirrored string
+	}{
+		{"rnbqkbnr/pppp1ppp/8/8/3pP3/8/PPP2PPP/RNBQKBNR b KQkq e3 0 3", "rnbkqbnr/ppp1pppp/8/8/3Pp3/8/PPP2PPP/RNBKQBNR b - d3 0 3"},
+		{"1r4kr/8/8/8/8/8/8/1R4KR w HBhb - 0 1", "rk4r1/8/8/8/8/8/8/RK4R1 w GAga - 0 1"},
+	}
+	for _, test := range tests {
+		pos := unsafeFEN(test.fen)
+		mirrored := pos.Mirror()
+		if mirrored.String() != test.mirrored {
+			t.Fatalf("expected %s mirrored to be %s but got %s", test.fen, test.mirrored, mirrored)
+		}
+	}
+	pos := unsafeFEN("1r4kr/8/8/8/8/8/8/1R4KR w HBhb - 0 1")
+	if back := pos.Mirror().Mirror(); back.String() != pos.String() {
+		t.Fatalf("expected position mirrored twice to be unchanged but got %s", back)
+	}
+}
+
+func TestPositionPieces(t *testing.T) {
+	pos := StartingPosition()
+	tests := []struct {
+		c   Color
+		pt  PieceType
+		sqs []Square
+	}{
+		{White, Knight, []Square{B1, G1}},
+		{Black, Rook, []Square{A8, H8}},
+		{White, King, []Square{E1}},
+		{Black, Pawn, []Square{A7, B7, C7, D7, E7, F7, G7, H7}},
+		{White, NoPieceType, []Square{}},
+	}
+	for _, test := range tests {
+		sqs := pos.Pieces(test.c, test.pt)
+		if len(sqs) != len(test.sqs) {
+			t.Fatalf("expected %s %s on %v but got %v", test.c, test.pt, test.sqs, sqs)
+		}
+		for i := range sqs {
+			if sqs[i] != test.sqs[i] {
+				t.Fatalf("expected %s %s on %v but got %v", test.c, test.pt, test.sqs, sqs)
+			}
+		}
+	}
+}
+
+func BenchmarkPositionPieces(b *testing.B) {
+	pos := StartingPosition()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		pos.Pieces(White, Pawn)
+	}
+}
+
+func TestPositionBitboard(t *testing.T) {
+	pos := StartingPosition()
+	tests := []struct {
+		c  Color
+		pt PieceType
+		bb uint64
+	}{
+		{White, Pawn, 0xFF00},
+		{Black, Pawn, 0xFF000000000000},
+		{White, Rook, 1<<uint(A1) | 1<<uint(H1)},
+		{Black, King, 1 << uint(E8)},
+		{Black, NoPieceType, 0},
+	}
+	for _, test := range tests {
+		if bb := pos.Bitboard(test.c, test.pt); bb != test.bb {
+			t.Fatalf("expected %s %s bitboard %x but got %x", test.c, test.pt, test.bb, bb)
+		}
+	}
+	if bb := pos.OccupiedBitboard(); bb != 0xFFFF00000000FFFF {
+		t.Fatalf("expected occupied bitboard %x but got %x", uint64(0xFFFF00000000FFFF), bb)
+	}
+}
+
+func TestPositionNullMove(t *testing.T) {
+	pos := unsafeFEN("rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2")
+	null, err := pos.NullMove()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 1 2"
+	if null.String() != expected {
+		t.Fatalf("expected null move position %s but got %s", expected, null)
+	}
+	if pos.String() != "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2" {
+		t.Fatalf("expected the original position to be unchanged but got %s", pos)
+	}
+	if len(null.ValidMoves()) != 29 {
+		t.Fatalf("expected black to have 29 moves after the null move but got %d", len(null.ValidMoves()))
+	}
+	inCheck := unsafeFEN("rnb1kbnr/pppp1ppp/8/4p3/5PPq/8/PPPPP2P/RNBQKBNR w KQkq - 1 3")
+	if _, err := inCheck.NullMove(); err == nil {
+		t.Fatal("expected an error for a null move while in check")
+	}
+}
+
+func TestPositionConcurrentValidMoves(t *testing.T) {
+	pos := unsafeFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	var wg sync.WaitGroup
+	counts := make([]int, 8)
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			counts[i] = len(pos.ValidMoves()) + pos.NumLegalMoves()
+			pos.Status()
+		}(i)
+	}
+	wg.Wait()
+	for _, n := range counts {
+		if n != 96 {
+			t.Fatalf("expected 48 moves from each goroutine but got %v", counts)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package chess
+
+// pieceValues are the conventional values of the piece types in
+// centipawns.  The king's value is only used to keep it from being
+// traded in exchanges.
+var pieceValues = map[PieceType]int{
+	Pawn:   100,
+	Knight: 300,
+	Bishop: 300,
+	Rook:   500,
+	Queen:  900,
+	King:   20000,
+}
+
+// SEE returns the static exchange evaluation of the move in centipawns.
+// It is the material won or lost, from the moving side's perspective, in
+// the sequence of captures on the move's destination where each side
+// recaptures with its least valuable attacker and can stop when continuing
+// would lose material.  Attacks revealed behind sliding pieces are included.
+// Losing captures return a negative value and a quiet move to a square the
+// piece would be lost on does as well.  The move isn't validated.
+func (pos *Position) SEE(m *Move) int {
+	b := pos.board
+	attacker := b.Piece(m.s1)
+	if attacker == NoPiece {
+		return 0
+	}
+	var gain [32]int
+	gain[0] = pieceValues[b.Piece(m.s2).Type()]
+	occ := ^b.emptySqs & ^bbForSquare(m.s1)
+	if m.HasTag(EnPassant) || (attacker.Type() == Pawn && m.s2 == pos.enPassantSquare) {
+		gain[0] = pieceValues[Pawn]
+		capSq := m.s2 - 8
+		if attacker.Color() == Black {
+			capSq = m.s2 + 8
+		}
+		occ &= ^bbForSquare(capSq)
+	}
+	value := pieceValues[attacker.Type()]
+	if m.promo != NoPieceType {
+		gain[0] += pieceValues[m.promo] - pieceValues[Pawn]
+		value = pieceValues[m.promo]
+	}
+	c := attacker.Color()
+	d := 0
+	for d < len(gain)-1 {
+		c = c.Other()
+		sq, pt := leastValuableAttacker(b, occ, m.s2, c)
+		if sq == NoSquare {
+			break
+		}
+		d++
+		// the gain for the side capturing if there are no recaptures
+		gain[d] = value - gain[d-1]
+		occ &= ^bbForSquare(sq)
+		value = pieceValues[pt]
+	}
+	// each side only captures if it is better than stopping
+	for ; d > 0; d-- {
+		if gain[d] > -gain[d-1] {
+			gain[d-1] = -gain[d]
+		}
+	}
+	return gain[0]
+}
+
+// IsQuiet returns true if the side to move isn't in check and has no
+// capture that wins or keeps material, meaning an SEE of zero or more.
+// Quiescence searches can stop at quiet positions.  Promotions that
+// don't capture don't make a position unquiet.
+func (pos *Position) IsQuiet() bool {
+	if pos.inCheck {
+		return false
+	}
+	quiet := true
+	pos.EachMove(func(m Move) bool {
+		if (m.HasTag(Capture) || m.HasTag(EnPassant)) && pos.SEE(&m) >= 0 {
+			quiet = false
+		}
+		return quiet
+	})
+	return quiet
+}
+
+func leastValuableAttacker(b *Board, occ bitboard, sq Square, c Color) (Square, PieceType) {
+	attackers := attackersOccBB(b, occ, sq, c)
+	if attackers == 0 {
+		return NoSquare, NoPieceType
+	}
+	for _, pt := range []PieceType{Pawn, Knight, Bishop, Rook, Queen, King} {
+		bb := attackers & b.bbForPiece(NewPiece(pt, c))
+		if bb != 0 {
+			return bb.first(), pt
+		}
+	}
+	return NoSquare, NoPieceType
+}
@@ -0,0 +1,66 @@
+package chess
+
+import "testing"
+
+func TestSEE(t *testing.T) {
+	tests := []struct {
+		fen  string
+		move *Move
+		see  int
+	}{
+		// undefended pawn
+		{"1k1r4/1pp4p/p7/4p3/8/P5P1/1PP4P/2K1R3 w - - 0 1", &Move{s1: E1, s2: E5}, 100},
+		// pawn defended by a knight behind which is a bishop
+		{"1k1r3q/1ppn3p/p4b2/4p3/8/P2N2P1/1PP1R1BP/2K1Q3 w - - 0 1", &Move{s1: D3, s2: E5}, -200},
+		// knight takes a pawn defended by a pawn
+		{"4k3/8/3p4/4p3/8/5N2/8/4K3 w - - 0 1", &Move{s1: F3, s2: E5}, -200},
+		// pawn takes a knight defended by a pawn
+		{"4k3/8/3p4/4n3/3P4/8/8/4K3 w - - 0 1", &Move{s1: D4, s2: E5}, 200},
+		// queen takes a pawn defended by a pawn
+		{"4k3/8/3p4/4p3/8/8/8/4K2Q w - - 0 1", &Move{s1: H1, s2: E4}, 0},
+		{"4k3/8/3p4/4p3/8/8/1Q6/4K3 w - - 0 1", &Move{s1: B2, s2: E5}, -800},
+		// rook takes a pawn defended only by the king with x-ray support behind
+		{"8/8/8/3kp3/8/8/4R3/4R1K1 w - - 0 1", &Move{s1: E2, s2: E5}, 100},
+		// the king can't recapture a defended piece
+		{"8/8/8/3kp3/8/8/4R3/6K1 w - - 0 1", &Move{s1: E2, s2: E5}, -400},
+		// en passant
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", &Move{s1: E5, s2: D6, tags: EnPassant}, 100},
+		// quiet move to a square attacked by a pawn
+		{"4k3/8/3p4/8/8/8/8/2B1K3 w - - 0 1", &Move{s1: C1, s2: E5}, -300},
+		// promotion capture
+		{"r3k3/1P6/8/8/8/8/8/4K3 w - - 0 1", &Move{s1: B7, s2: A8, promo: Queen}, 1300},
+	}
+	for _, test := range tests {
+		pos := unsafeFEN(test.fen)
+		if see := pos.SEE(test.move); see != test.see {
+			t.Fatalf("expected SEE of %s in %s to be %d but got %d", test.move, test.fen, test.see, see)
+		}
+	}
+}
+
+func TestIsQuiet(t *testing.T) {
+	tests := []struct {
+		fen   string
+		quiet bool
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", true},
+		// the rook can take the undefended pawn
+		{"1k1r4/1pp4p/p7/4p3/8/P5P1/1PP4P/2K1R3 w - - 0 1", false},
+		// the only capture loses the knight for a pawn
+		{"4k3/8/3p4/4p3/8/5N2/8/4K3 w - - 0 1", true},
+		// pawns trading evenly isn't quiet
+		{"4k3/8/3p4/4p3/3P4/8/8/4K3 w - - 0 1", false},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", false},
+		// a position in check isn't quiet
+		{"4k3/8/8/8/8/8/8/4K2r w - - 0 1", false},
+	}
+	for _, test := range tests {
+		pos, err := NewPositionFromFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos.IsQuiet() != test.quiet {
+			t.Fatalf("expected quiet to be %t for %s", test.quiet, test.fen)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package chess
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 )
 
 const (
@@ -53,6 +55,81 @@ func NewSquare(f File, r Rank) Square {
 	return Square(int8(r)*numOfSquaresInRow + int8(f))
 }
 
+// ParseSquare returns the square for its algebraic coordinates such as
+// "e4".  The file letter can be upper or lower case.  An error is
+// returned for anything else such as "z9" or "e".
+func ParseSquare(s string) (Square, error) {
+	sq, ok := strToSquareMap[strings.ToLower(s)]
+	if !ok {
+		return NoSquare, fmt.Errorf("chess: invalid square %q", s)
+	}
+	return sq, nil
+}
+
+// Index returns the square's index from 0 for A1 to 63 for H8 counting
+// the files of each rank from A to H, or -1 for NoSquare.
+func (sq Square) Index() int {
+	if sq < A1 || sq > H8 {
+		return -1
+	}
+	return int(sq)
+}
+
+// SquareFromIndex returns the square with the given index as returned by
+// Index or NoSquare if the index isn't from 0 to 63.
+func SquareFromIndex(i int) Square {
+	if i < 0 || i >= numOfSquaresInBoard {
+		return NoSquare
+	}
+	return Square(i)
+}
+
+// Offset returns the square df files and dr ranks away from the square.
+// Positive offsets move towards the h file and the eighth rank.  The
+// returned bool is false if the resulting square would be off the board.
+func (sq Square) Offset(df, dr int) (Square, bool) {
+	if sq < A1 || sq > H8 {
+		return NoSquare, false
+	}
+	f := int(sq.File()) + df
+	r := int(sq.Rank()) + dr
+	if f < 0 || f >= numOfSquaresInRow || r < 0 || r >= numOfSquaresInRow {
+		return NoSquare, false
+	}
+	return NewSquare(File(f), Rank(r)), true
+}
+
+// SquaresBetween returns the squares strictly between a and b ordered
+// from a to b when they share a rank, file or diagonal.  The returned
+// slice is empty if they don't or if they are the same or adjacent
+// squares.
+func SquaresBetween(a, b Square) []Square {
+	squares := []Square{}
+	if a < A1 || a > H8 || b < A1 || b > H8 || a == b {
+		return squares
+	}
+	df := int(b.File()) - int(a.File())
+	dr := int(b.Rank()) - int(a.Rank())
+	if df != 0 && dr != 0 && df != dr && df != -dr {
+		return squares
+	}
+	df, dr = sign(df), sign(dr)
+	for sq, _ := a.Offset(df, dr); sq != b; sq, _ = sq.Offset(df, dr) {
+		squares = append(squares, sq)
+	}
+	return squares
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
+
 func (sq Square) color() Color {
 	if ((sq / 8) % 2) == (sq % 2) {
 		return Black
@@ -128,6 +205,26 @@ const (
 	H8
 )
 
+// AllSquares returns the 64 squares in order from A1 to H8, rank by rank
+// with the files of each rank from A to H.
+func AllSquares() []Square {
+	squares := make([]Square, 0, numOfSquaresInBoard)
+	for sq := A1; sq <= H8; sq++ {
+		squares = append(squares, sq)
+	}
+	return squares
+}
+
+// AllFiles returns the files in order from FileA to FileH.
+func AllFiles() []File {
+	return []File{FileA, FileB, FileC, FileD, FileE, FileF, FileG, FileH}
+}
+
+// AllRanks returns the ranks in order from Rank1 to Rank8.
+func AllRanks() []Rank {
+	return []Rank{Rank1, Rank2, Rank3, Rank4, Rank5, Rank6, Rank7, Rank8}
+}
+
 const (
 	fileChars = "abcdefgh"
 	rankChars = "12345678"
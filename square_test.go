@@ -1,6 +1,9 @@
 package chess
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 type newSquareTest struct {
 	f  File
@@ -27,3 +30,104 @@ func TestNewSquare(t *testing.T) {
 		}
 	}
 }
+
+func TestSquareOffset(t *testing.T) {
+	testCases := []struct {
+		sq     Square
+		df, dr int
+		result Square
+		ok     bool
+	}{
+		{E4, 0, 0, E4, true},
+		{E4, 1, 2, F6, true},
+		{E4, -4, -3, A1, true},
+		{B1, -1, 2, A3, true},
+		{H4, 1, 0, NoSquare, false},
+		{A4, -1, 0, NoSquare, false},
+		{E8, 0, 1, NoSquare, false},
+		{E1, 0, -1, NoSquare, false},
+		{H1, 1, 1, NoSquare, false},
+		{NoSquare, 0, 0, NoSquare, false},
+	}
+	for _, testCase := range testCases {
+		sq, ok := testCase.sq.Offset(testCase.df, testCase.dr)
+		if sq != testCase.result || ok != testCase.ok {
+			t.Fatalf("expected %s offset by (%d, %d) to be %s %t, got %s %t",
+				testCase.sq, testCase.df, testCase.dr, testCase.result, testCase.ok, sq, ok)
+		}
+	}
+}
+
+func TestSquaresBetween(t *testing.T) {
+	testCases := []struct {
+		a, b   Square
+		result []Square
+	}{
+		{A1, A4, []Square{A2, A3}},
+		{H8, C3, []Square{G7, F6, E5, D4}},
+		{E1, H1, []Square{F1, G1}},
+		{B6, E3, []Square{C5, D4}},
+		{E4, E5, []Square{}},
+		{E4, E4, []Square{}},
+		{B1, C3, []Square{}},
+		{A1, H7, []Square{}},
+		{NoSquare, E4, []Square{}},
+	}
+	for _, testCase := range testCases {
+		squares := SquaresBetween(testCase.a, testCase.b)
+		if fmt.Sprint(squares) != fmt.Sprint(testCase.result) {
+			t.Fatalf("expected squares between %s and %s to be %v, got %v",
+				testCase.a, testCase.b, testCase.result, squares)
+		}
+	}
+}
+
+func TestAllSquares(t *testing.T) {
+	squares := AllSquares()
+	if len(squares) != 64 || squares[0] != A1 || squares[7] != H1 || squares[8] != A2 || squares[63] != H8 {
+		t.Fatalf("expected the squares from a1 to h8 but got %v", squares)
+	}
+	i := 0
+	for _, r := range AllRanks() {
+		for _, f := range AllFiles() {
+			if sq := NewSquare(f, r); squares[i] != sq || sq.File() != f || sq.Rank() != r {
+				t.Fatalf("expected square %d to be %s%s but got %s", i, f, r, squares[i])
+			}
+			i++
+		}
+	}
+	if fmt.Sprint(AllFiles()) != "[a b c d e f g h]" || fmt.Sprint(AllRanks()) != "[1 2 3 4 5 6 7 8]" {
+		t.Fatalf("expected files a to h and ranks 1 to 8 but got %v and %v", AllFiles(), AllRanks())
+	}
+}
+
+func TestParseSquare(t *testing.T) {
+	for _, sq := range AllSquares() {
+		parsed, err := ParseSquare(sq.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed != sq {
+			t.Fatalf("expected %s but got %s", sq, parsed)
+		}
+		if SquareFromIndex(sq.Index()) != sq {
+			t.Fatalf("expected index %d to be %s", sq.Index(), sq)
+		}
+	}
+	if sq, err := ParseSquare("E4"); err != nil || sq != E4 {
+		t.Fatalf("expected e4 but got %s %v", sq, err)
+	}
+	for _, s := range []string{"", "e", "z9", "e9", "i1", "e44", " e4"} {
+		if sq, err := ParseSquare(s); err == nil || sq != NoSquare {
+			t.Fatalf("expected an error parsing %q but got %s", s, sq)
+		}
+	}
+	if A1.Index() != 0 || H1.Index() != 7 || H8.Index() != 63 || NoSquare.Index() != -1 {
+		t.Fatal("expected indexes from 0 for a1 to 63 for h8")
+	}
+	for _, i := range []int{-1, 64} {
+		if sq := SquareFromIndex(i); sq != NoSquare {
+			t.Fatalf("expected no square for index %d but got %s", i, sq)
+		}
+	}
+}
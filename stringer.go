@@ -4,9 +4,9 @@ package chess
 
 import "fmt"
 
-const _Method_name = "NoMethodCheckmateResignationDrawOfferStalemateThreefoldRepetitionFivefoldRepetitionFiftyMoveRuleSeventyFiveMoveRuleInsufficientMaterial"
+const _Method_name = "NoMethodCheckmateResignationDrawOfferStalemateThreefoldRepetitionFivefoldRepetitionFiftyMoveRuleSeventyFiveMoveRuleInsufficientMaterialTimeoutKingInCenterThirdCheckKnownWinKnownDraw"
 
-var _Method_index = [...]uint8{0, 8, 17, 28, 37, 46, 65, 83, 96, 115, 135}
+var _Method_index = [...]uint8{0, 8, 17, 28, 37, 46, 65, 83, 96, 115, 135, 142, 154, 164, 172, 181}
 
 func (i Method) String() string {
 	if i >= Method(len(_Method_index)-1) {
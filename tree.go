@@ -0,0 +1,298 @@
+package chess
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A MoveNode is a node in a game's move tree.  The root node holds the
+// game's starting position and every other node holds a move along with
+// the position it results in.  A node's first child continues its line
+// and any other children are variations that are alternatives to it.
+type MoveNode struct {
+	move     *Move
+	pos      *Position
+	comments []string
+	nags     []int
+	parent   *MoveNode
+	children []*MoveNode
+}
+
+// Move returns the node's move or nil for the root node.
+func (n *MoveNode) Move() *Move {
+	return n.move
+}
+
+// Position returns the position after the node's move or the starting
+// position for the root node.
+func (n *MoveNode) Position() *Position {
+	return n.pos
+}
+
+// Comments returns the comments for the node's move or the comments
+// before the first move for the root node.
+func (n *MoveNode) Comments() []string {
+	return append([]string(nil), n.comments...)
+}
+
+// NAGs returns the numeric annotation glyphs for the node's move.
+func (n *MoveNode) NAGs() []int {
+	return append([]int(nil), n.nags...)
+}
+
+// Parent returns the node the node's move was played from or nil for
+// the root node.
+func (n *MoveNode) Parent() *MoveNode {
+	return n.parent
+}
+
+// Children returns the nodes for the moves played from the node.  The
+// first child continues the node's line and the rest are variations.
+func (n *MoveNode) Children() []*MoveNode {
+	return append([]*MoveNode(nil), n.children...)
+}
+
+// Next returns the node's first child which continues its line or nil
+// if the line ends at the node.
+func (n *MoveNode) Next() *MoveNode {
+	if len(n.children) == 0 {
+		return nil
+	}
+	return n.children[0]
+}
+
+// Variations returns the other children of the node's parent which are
+// the moves that are alternatives to the node's move.
+func (n *MoveNode) Variations() []*MoveNode {
+	nodes := []*MoveNode{}
+	if n.parent == nil {
+		return nodes
+	}
+	for _, c := range n.parent.children {
+		if c != n {
+			nodes = append(nodes, c)
+		}
+	}
+	return nodes
+}
+
+func (n *MoveNode) copy(parent *MoveNode) *MoveNode {
+	cp := &MoveNode{
+		move:     n.move,
+		pos:      n.pos,
+		comments: n.Comments(),
+		nags:     n.NAGs(),
+		parent:   parent,
+	}
+	for _, c := range n.children {
+		cp.children = append(cp.children, c.copy(cp))
+	}
+	return cp
+}
+
+// clone is like copy but also copies the moves and positions.
+func (n *MoveNode) clone(parent *MoveNode) *MoveNode {
+	cp := n.copy(parent)
+	var deep func(*MoveNode)
+	deep = func(c *MoveNode) {
+		if c.move != nil {
+			mv := *c.move
+			c.move = &mv
+		}
+		if c.pos != nil {
+			c.pos = c.pos.copy()
+		}
+		for _, child := range c.children {
+			deep(child)
+		}
+	}
+	deep(cp)
+	return cp
+}
+
+// Tree returns the root of the game's move tree.  Following the first
+// child of each node from the root gives the game's moves and any
+// variations read from PGN are given as the other children.  The tree is
+// a copy so it isn't changed by later moves.
+func (g *Game) Tree() *MoveNode {
+	root := &MoveNode{pos: g.positions[0], comments: append([]string(nil), g.initialComments...)}
+	n := root
+	for i, m := range g.moves {
+		child := &MoveNode{move: m, pos: g.positions[i+1], parent: n}
+		if len(g.comments) > i {
+			child.comments = append([]string(nil), g.comments[i]...)
+		}
+		if len(g.nags) > i {
+			child.nags = append([]int(nil), g.nags[i]...)
+		}
+		n.children = append(n.children, child)
+		if len(g.variations) > i {
+			for _, v := range g.variations[i] {
+				n.children = append(n.children, v.copy(n))
+			}
+		}
+		n = child
+	}
+	return root
+}
+
+// A Cursor steps through a game's move tree for building step through
+// interfaces such as PGN viewers.  It starts at the starting position
+// and follows the main line unless it is moved into a variation with
+// Branch.
+type Cursor struct {
+	node *MoveNode
+	ply  int
+}
+
+// Cursor returns a cursor at the game's starting position.  The cursor
+// walks a copy of the game's move tree so it isn't changed by later
+// moves.
+func (g *Game) Cursor() *Cursor {
+	return &Cursor{node: g.Tree()}
+}
+
+// Next moves the cursor forward one move along its line and returns
+// true or false if the line ends at the cursor.
+func (c *Cursor) Next() bool {
+	return c.Branch(0)
+}
+
+// Prev moves the cursor back one move and returns true or false if the
+// cursor is at the starting position.  Stepping back from the first
+// move of a variation leaves the variation.
+func (c *Cursor) Prev() bool {
+	if c.node.parent == nil {
+		return false
+	}
+	c.node = c.node.parent
+	c.ply--
+	return true
+}
+
+// Branch moves the cursor forward to the i-th move played from the
+// cursor's position and returns true or false if there isn't one.  Zero
+// is the move continuing the line like Next and higher indexes enter
+// the variations to it in order.
+func (c *Cursor) Branch(i int) bool {
+	if i < 0 || i >= len(c.node.children) {
+		return false
+	}
+	c.node = c.node.children[i]
+	c.ply++
+	return true
+}
+
+// Goto moves the cursor to the given ply of its line with zero being the
+// starting position.  Plies past the cursor follow the line's first
+// children.  An error is returned and the cursor isn't moved if the
+// line doesn't reach the ply.
+func (c *Cursor) Goto(ply int) error {
+	n, at := c.node, c.ply
+	for ; at > ply && n.parent != nil; at-- {
+		n = n.parent
+	}
+	for ; at < ply && len(n.children) > 0; at++ {
+		n = n.children[0]
+	}
+	if at != ply {
+		return fmt.Errorf("chess: ply %d is out of range for the cursor's line", ply)
+	}
+	c.node, c.ply = n, at
+	return nil
+}
+
+// Ply returns the number of moves from the starting position to the
+// cursor.
+func (c *Cursor) Ply() int {
+	return c.ply
+}
+
+// Position returns the position at the cursor.
+func (c *Cursor) Position() *Position {
+	return c.node.pos
+}
+
+// Move returns the move that led to the cursor's position or nil at the
+// starting position.
+func (c *Cursor) Move() *Move {
+	return c.node.move
+}
+
+// Node returns the move tree node at the cursor which has the comments,
+// NAGs and variations of its move.
+func (c *Cursor) Node() *MoveNode {
+	return c.node
+}
+
+// Merge returns a game combining the move trees of the games, such as
+// games sharing an opening.  The merged game is a copy of the first game
+// with the moves of the other games added: where a line diverges from
+// one already in the tree, its remaining moves become a variation off
+// the shared move and where it continues past the end of the first
+// game's moves, the main line is extended and the outcome is reset.
+// Comments and NAGs are taken from the first game with a move.  An error
+// is returned if no games are given or if they don't share a starting
+// position.
+func Merge(games ...*Game) (*Game, error) {
+	if len(games) == 0 {
+		return nil, errors.New("chess: merge requires at least one game")
+	}
+	root := games[0].Tree()
+	for i, g := range games[1:] {
+		if !g.positions[0].SamePosition(root.pos) {
+			return nil, fmt.Errorf("chess: merge game %d starts from %s rather than %s", i+2, g.positions[0], root.pos)
+		}
+		root.merge(g.Tree())
+	}
+	merged := games[0].Clone()
+	for len(merged.variations) < len(merged.moves) {
+		merged.variations = append(merged.variations, nil)
+	}
+	n := root
+	for i := 0; n.Next() != nil; i++ {
+		next := n.Next()
+		if i == len(merged.moves) {
+			if i == len(games[0].moves) {
+				merged.outcome = NoOutcome
+				merged.method = NoMethod
+			}
+			if err := merged.Move(next.move); err != nil {
+				return nil, err
+			}
+			for len(merged.comments) < i {
+				merged.comments = append(merged.comments, nil)
+			}
+			for len(merged.nags) < i {
+				merged.nags = append(merged.nags, nil)
+			}
+			merged.comments = append(merged.comments, next.Comments())
+			merged.nags = append(merged.nags, next.NAGs())
+			merged.variations = append(merged.variations, nil)
+		}
+		merged.variations[i] = nil
+		for _, v := range n.children[1:] {
+			merged.variations[i] = append(merged.variations[i], v.clone(nil))
+		}
+		n = next
+	}
+	return merged, nil
+}
+
+// merge adds the moves in the tree of src that aren't in the tree of n.
+func (n *MoveNode) merge(src *MoveNode) {
+	for _, c := range src.children {
+		var match *MoveNode
+		for _, d := range n.children {
+			if d.move.Equal(*c.move) {
+				match = d
+				break
+			}
+		}
+		if match == nil {
+			n.children = append(n.children, c.copy(n))
+			continue
+		}
+		match.merge(c)
+	}
+}
@@ -0,0 +1,152 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Variant is a set of rules the game is played with.
+type Variant int
+
+const (
+	// Standard is standard chess.
+	Standard Variant = iota
+	// Chess960 is standard chess from one of 960 starting positions
+	// with castling moving the king and rook to their usual squares.
+	Chess960
+	// Crazyhouse lets captured pieces be dropped back onto the board.
+	Crazyhouse
+	// KingOfTheHill is also won by moving the king to a center square.
+	KingOfTheHill
+	// ThreeCheck is also won by giving check three times.
+	ThreeCheck
+	// Horde has white with a horde of pawns and no king against black's
+	// standard army.
+	Horde
+	// Atomic has captures explode the pieces around the capture square.
+	Atomic
+)
+
+var variantNames = []string{"Standard", "Chess960", "Crazyhouse", "King of the Hill", "Three-check", "Horde", "Atomic"}
+
+// String implements the fmt.Stringer interface and returns the
+// variant's name as used in PGN Variant tags such as "King of the Hill".
+func (v Variant) String() string {
+	if v < 0 || int(v) >= len(variantNames) {
+		return fmt.Sprintf("Variant(%d)", int(v))
+	}
+	return variantNames[v]
+}
+
+// ParseVariant returns the variant with the given name.  Names are
+// matched ignoring case, spaces, dashes and underscores so both PGN tag
+// values like "King of the Hill" and Lichess keys like "kingOfTheHill"
+// are accepted, as is "From Position" for standard chess from a FEN.  An
+// error is returned if the name isn't a known variant.
+func ParseVariant(s string) (Variant, error) {
+	key := variantKey(s)
+	switch key {
+	case "", "fromposition", "chess":
+		return Standard, nil
+	case "fischerandom", "fischerrandom":
+		return Chess960, nil
+	case "koth":
+		return KingOfTheHill, nil
+	case "3check":
+		return ThreeCheck, nil
+	}
+	for i, name := range variantNames {
+		if variantKey(name) == key {
+			return Variant(i), nil
+		}
+	}
+	return Standard, fmt.Errorf("chess: unknown variant %q", s)
+}
+
+func variantKey(s string) string {
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToLower(strings.TrimSpace(s)))
+}
+
+// supportedVariants are the variants NewVariantPosition can create.
+var supportedVariants = map[Variant]bool{Standard: true, Chess960: true, Crazyhouse: true, KingOfTheHill: true, ThreeCheck: true}
+
+var bbCenter = (bbRank4 | bbRank5) & (bbFileD | bbFileE)
+
+// Variant returns the rules the position is played with.  Positions
+// from FENs with Shredder-FEN castle rights are Chess960 positions and
+// those with a pocket after the board are Crazyhouse positions.
+func (pos *Position) Variant() Variant {
+	return pos.variant
+}
+
+// NewVariantPosition returns the position described by the FEN played
+// with the variant's rules, such as for the FEN and Variant tags of a
+// Lichess game.  The FEN doesn't need a Crazyhouse pocket and its KQkq
+// castle rights are read as the outermost rooks for Chess960.  An error
+// is returned if the FEN is invalid or the variant isn't supported.
+func NewVariantPosition(v Variant, fen string) (*Position, error) {
+	if !supportedVariants[v] {
+		return nil, fmt.Errorf("chess: variant %s is not supported", v)
+	}
+	pos, err := NewPositionFromFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	if pos.variant != Standard && pos.variant != v {
+		return nil, fmt.Errorf("chess: fen %s is a %s position rather than %s", fen, pos.variant, v)
+	}
+	if v == Chess960 && !pos.chess960 {
+		pos.chess960 = true
+		rights := pos.castleRights
+		pos.castleRooks = [4]Square{NoSquare, NoSquare, NoSquare, NoSquare}
+		pos = pos.WithCastleRights(rights)
+		if pos.castleRights != rights {
+			return nil, fmt.Errorf("%w: %s for chess960 position %s", ErrFENCastleRights, rights, fen)
+		}
+	}
+	pos.variant = v
+	return pos, nil
+}
+
+// variantStatus returns the method that ended the game by the rules of
+// the position's variant or NoMethod if the variant's own win conditions
+// weren't met.  They are checked before checkmate since they end the
+// game immediately.
+func (pos *Position) variantStatus() Method {
+	for _, method := range []Method{KingInCenter, ThirdCheck} {
+		if pos.variantWinner(method) != NoColor {
+			return method
+		}
+	}
+	return NoMethod
+}
+
+// variantWinner returns the color that won the position by the variant
+// method or NoColor if the method doesn't apply.
+func (pos *Position) variantWinner(method Method) Color {
+	switch {
+	case method == KingInCenter && pos.variant == KingOfTheHill:
+		for _, c := range []Color{White, Black} {
+			if pos.board.bbForPiece(NewPiece(King, c))&bbCenter != 0 {
+				return c
+			}
+		}
+	case method == ThirdCheck && pos.variant == ThreeCheck:
+		for _, c := range []Color{White, Black} {
+			if pos.checks[c] >= 3 {
+				return c
+			}
+		}
+	}
+	return NoColor
+}
+
+// CheckCount returns the number of times the color has given check in a
+// Three-check position.  Counting starts from the position the game was
+// created with and is zero for other variants.
+func (pos *Position) CheckCount(c Color) int {
+	if c != White && c != Black {
+		return 0
+	}
+	return int(pos.checks[c])
+}
@@ -0,0 +1,187 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseVariant(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected Variant
+	}{
+		{"", Standard},
+		{"Standard", Standard},
+		{"From Position", Standard},
+		{"chess960", Chess960},
+		{"Fischerandom", Chess960},
+		{"crazyhouse", Crazyhouse},
+		{"King of the Hill", KingOfTheHill},
+		{"kingOfTheHill", KingOfTheHill},
+		{"Three-check", ThreeCheck},
+		{"threeCheck", ThreeCheck},
+		{"horde", Horde},
+		{"Atomic", Atomic},
+	}
+	for _, test := range tests {
+		v, err := ParseVariant(test.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != test.expected {
+			t.Fatalf("expected %q to be %s but got %s", test.s, test.expected, v)
+		}
+	}
+	if _, err := ParseVariant("bughouse"); err == nil {
+		t.Fatal("expected an error for an unknown variant")
+	}
+	if KingOfTheHill.String() != "King of the Hill" {
+		t.Fatalf("expected King of the Hill but got %s", KingOfTheHill)
+	}
+}
+
+func TestPositionVariant(t *testing.T) {
+	if v := StartingPosition().Variant(); v != Standard {
+		t.Fatalf("expected the starting position to be Standard but got %s", v)
+	}
+	pos, err := NewChess960Position(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.Variant() != Chess960 {
+		t.Fatalf("expected a Chess960 position but got %s", pos.Variant())
+	}
+	pos = unsafeFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1")
+	if pos.Variant() != Crazyhouse {
+		t.Fatalf("expected a Crazyhouse position but got %s", pos.Variant())
+	}
+	next, err := pos.Move(&Move{s1: E2, s2: E4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Variant() != Crazyhouse {
+		t.Fatalf("expected the variant to be kept after a move but got %s", next.Variant())
+	}
+}
+
+func TestNewVariantPosition(t *testing.T) {
+	pos, err := NewVariantPosition(Crazyhouse, startFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.Variant() != Crazyhouse || !strings.Contains(pos.String(), "[]") {
+		t.Fatalf("expected a Crazyhouse position with an empty pocket but got %s", pos)
+	}
+	pos, err = NewVariantPosition(Chess960, "rk2r3/8/8/8/8/8/8/RK2R3 w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.Variant() != Chess960 || !strings.Contains(pos.String(), " EAea ") {
+		t.Fatalf("expected a Chess960 position castling with the outermost rooks but got %s", pos)
+	}
+	for _, v := range []Variant{Horde, Atomic} {
+		if _, err := NewVariantPosition(v, startFEN); err == nil {
+			t.Fatalf("expected an error for unsupported variant %s", v)
+		}
+	}
+	if _, err := NewVariantPosition(Chess960, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1"); err == nil {
+		t.Fatal("expected an error for a Crazyhouse fen read as Chess960")
+	}
+	if _, err := NewVariantPosition(Chess960, "4k3/8/8/8/8/8/8/4K3 w KQ - 0 1"); err == nil {
+		t.Fatal("expected an error for castle rights without rooks")
+	}
+}
+
+func TestPGNVariantTag(t *testing.T) {
+	pgn := `[Variant "Crazyhouse"]
+
+1. e4 d5 2. exd5 Qxd5 3. Nc3 Qa5 4. P@d5 *`
+	opt, err := PGN(strings.NewReader(pgn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame(opt)
+	if g.Position().Variant() != Crazyhouse || g.Position().Pocket(Black)[Pawn] != 1 {
+		t.Fatalf("expected a Crazyhouse game but got %s", g.FEN())
+	}
+	if _, err := PGN(strings.NewReader(`[Variant "Atomic"]
+
+1. e4 *`)); err == nil {
+		t.Fatal("expected an error for an unsupported variant")
+	}
+}
+
+func TestKingOfTheHill(t *testing.T) {
+	opt, err := VariantFEN(KingOfTheHill, "4k3/8/8/8/8/4K3/8/8 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame(opt)
+	if g.Outcome() != NoOutcome {
+		t.Fatalf("expected lone kings to play on but got %s by %s", g.Outcome(), g.Method())
+	}
+	if err := g.MoveStr("Ke4"); err != nil {
+		t.Fatal(err)
+	}
+	if g.Outcome() != WhiteWon || g.Method() != KingInCenter || g.Position().Status() != KingInCenter {
+		t.Fatalf("expected white to win by reaching the center but got %s by %s", g.Outcome(), g.Method())
+	}
+	// the king can't reach the center by moving into check but wins by
+	// reaching it when it leaves all of black's moves stalemated
+	pos, err := NewVariantPosition(KingOfTheHill, "k7/8/1Q6/8/3r4/4K3/8/8 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := (AlgebraicNotation{}).Decode(pos, "Ke4"); err == nil {
+		t.Fatalf("expected Ke4 into the rook's check to be illegal but got %s", m)
+	}
+	pos, err = NewVariantPosition(KingOfTheHill, "k7/8/1Q6/8/8/4K3/8/8 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := pos.Move(&Move{s1: E3, s2: D4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Status() != KingInCenter {
+		t.Fatalf("expected reaching the center to win before stalemate but got %s", next.Status())
+	}
+	if StartingPosition().Variant() != Standard || unsafeFEN("4k3/8/8/8/4K3/8/8/8 b - - 0 1").Status() != InsufficientMaterial {
+		t.Fatal("expected a king in the center not to win standard chess")
+	}
+}
+
+func TestThreeCheck(t *testing.T) {
+	opt, err := VariantFEN(ThreeCheck, startFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame(opt)
+	for _, m := range []string{"e4", "e5", "Bb5", "c6", "Bxc6", "dxc6", "Qh5", "Nf6", "Qxf7+", "Kxf7"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := g.Position().CheckCount(White); n != 1 || g.Position().CheckCount(Black) != 0 {
+		t.Fatalf("expected white to have given one check but got %d", n)
+	}
+	for _, m := range []string{"d4", "Bb4+", "c3", "Bxc3+", "Nxc3", "Qxd4", "Nf3", "Qxf2+"} {
+		if err := g.MoveStr(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g.Position().CheckCount(Black) != 3 {
+		t.Fatalf("expected black to have given three checks but got %d", g.Position().CheckCount(Black))
+	}
+	if g.Outcome() != BlackWon || g.Method() != ThirdCheck {
+		t.Fatalf("expected black to win by the third check but got %s by %s", g.Outcome(), g.Method())
+	}
+	pos := unsafeFEN("4k3/8/8/8/8/8/8/4K2R w K - 0 1")
+	next, err := pos.Move(&Move{s1: H1, s2: H8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.CheckCount(White) != 0 {
+		t.Fatal("expected checks not to be counted in standard chess")
+	}
+}
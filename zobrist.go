@@ -0,0 +1,101 @@
+package chess
+
+// ZobristHash returns a 64-bit Zobrist hash of the position made from
+// keys for each piece on each square, the side to move, each castling
+// right, and the en passant file along with keys for the number of each
+// piece type in a Crazyhouse pocket and the number of checks given in
+// Three-check.  The keys are fixed so a position hashes to the same value
+// across runs and machines.  The en passant key is only mixed in when an
+// en passant capture is possible, so positions that only differ by an
+// unusable en passant square hash the same.  The half move clock and move
+// count aren't part of the hash.
+func (pos *Position) ZobristHash() uint64 {
+	var h uint64
+	for _, p := range allPieces {
+		for bb := pos.board.bbForPiece(p); bb != 0; {
+			sq := bb.first()
+			h ^= zobristPieceKeys[p-1][sq]
+			bb &^= bbForSquare(sq)
+		}
+	}
+	if pos.turn == Black {
+		h ^= zobristTurnKey
+	}
+	for i, c := range []Color{White, Black} {
+		if pos.castleRights.CanCastle(c, KingSide) {
+			h ^= zobristCastleKeys[i*2]
+		}
+		if pos.castleRights.CanCastle(c, QueenSide) {
+			h ^= zobristCastleKeys[i*2+1]
+		}
+	}
+	if sq := pos.validEnPassantSquare(); sq != NoSquare {
+		h ^= zobristEnPassantKeys[sq.File()]
+	}
+	for i, c := range []Color{White, Black} {
+		for _, pt := range dropPieceTypes {
+			if n := int(pos.pockets[c][pt]); n > 0 {
+				h ^= zobristPocketKeys[i][pt][zobristCount(n, 16)]
+			}
+		}
+		if n := int(pos.checks[c]); n > 0 {
+			h ^= zobristCheckKeys[i][zobristCount(n, 3)]
+		}
+	}
+	return h
+}
+
+// zobristCount returns the index of the key for a count from 1 with
+// counts above max sharing the last key.
+func zobristCount(n, max int) int {
+	if n > max {
+		n = max
+	}
+	return n - 1
+}
+
+var (
+	zobristPieceKeys     [12][numOfSquaresInBoard]uint64
+	zobristTurnKey       uint64
+	zobristCastleKeys    [4]uint64
+	zobristEnPassantKeys [8]uint64
+	zobristPocketKeys    [2][Pawn + 1][16]uint64
+	zobristCheckKeys     [2][3]uint64
+)
+
+func init() {
+	// splitmix64 with a fixed seed generates the keys
+	state := uint64(0x1d8e4e27c47d124f)
+	next := func() uint64 {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		return z ^ (z >> 31)
+	}
+	for p := range zobristPieceKeys {
+		for sq := range zobristPieceKeys[p] {
+			zobristPieceKeys[p][sq] = next()
+		}
+	}
+	zobristTurnKey = next()
+	for i := range zobristCastleKeys {
+		zobristCastleKeys[i] = next()
+	}
+	for i := range zobristEnPassantKeys {
+		zobristEnPassantKeys[i] = next()
+	}
+	// keys added later are generated last so earlier keys don't change
+	for c := range zobristPocketKeys {
+		for pt := range zobristPocketKeys[c] {
+			for n := range zobristPocketKeys[c][pt] {
+				zobristPocketKeys[c][pt][n] = next()
+			}
+		}
+	}
+	for c := range zobristCheckKeys {
+		for n := range zobristCheckKeys[c] {
+			zobristCheckKeys[c][n] = next()
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package chess
+
+import "testing"
+
+func TestZobristHashIsStable(t *testing.T) {
+	// the keys are fixed so this value should never change
+	const expected uint64 = 0x664304334827fb1f
+	if h := StartingPosition().ZobristHash(); h != expected {
+		t.Fatalf("expected starting position hash %#x but got %#x", expected, h)
+	}
+}
+
+func TestZobristHashTransposition(t *testing.T) {
+	g1 := NewGame()
+	for _, s := range []string{"Nc3", "e5", "Nf3"} {
+		if err := g1.MoveStr(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g2 := NewGame()
+	for _, s := range []string{"Nf3", "e5", "Nc3"} {
+		if err := g2.MoveStr(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g1.Position().ZobristHash() != g2.Position().ZobristHash() {
+		t.Fatalf("expected hashes to be equal but got %d and %d", g1.Position().ZobristHash(), g2.Position().ZobristHash())
+	}
+}
+
+func TestZobristHash(t *testing.T) {
+	tests := []struct {
+		fen1  string
+		fen2  string
+		equal bool
+	}{
+		// move counters are ignored
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 4 3", true},
+		// side to move
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1", false},
+		// castle rights
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "r3k2r/8/8/8/8/8/8/R3K2R w Kkq - 0 1", false},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "r3k2r/8/8/8/8/8/8/R3K2R w KQk - 0 1", false},
+		// en passant square without a possible capture
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1", true},
+		// en passant square with a possible capture
+		{"4k3/8/8/8/3pP3/8/8/4K3 b - e3 0 1", "4k3/8/8/8/3pP3/8/8/4K3 b - - 0 1", false},
+		// en passant capture that would leave the king in check
+		{"4K3/8/8/8/k2pP2R/8/8/8 b - e3 0 1", "4K3/8/8/8/k2pP2R/8/8/8 b - - 0 1", true},
+		// piece placement
+		{"4k3/8/8/8/8/8/8/4K2R w - - 0 1", "4k3/8/8/8/8/8/8/4KR2 w - - 0 1", false},
+	}
+	variants := []struct {
+		v     Variant
+		fen1  string
+		fen2  string
+		equal bool
+	}{
+		{Crazyhouse, "4k3/8/8/8/8/8/8/4K3[] w - - 0 1", "4k3/8/8/8/8/8/8/4K3[Q] w - - 0 1", false},
+		{Crazyhouse, "4k3/8/8/8/8/8/8/4K3[Q] w - - 0 1", "4k3/8/8/8/8/8/8/4K3[q] w - - 0 1", false},
+		{Crazyhouse, "4k3/8/8/8/8/8/8/4K3[P] w - - 0 1", "4k3/8/8/8/8/8/8/4K3[PP] w - - 0 1", false},
+		{Crazyhouse, "4k3/8/8/8/8/8/8/4K3[] w - - 0 1", "4k3/8/8/8/8/8/8/4K3 w - - 0 1", true},
+	}
+	for _, test := range variants {
+		pos1, err := NewVariantPosition(test.v, test.fen1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pos2, err := NewVariantPosition(test.v, test.fen2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (pos1.ZobristHash() == pos2.ZobristHash()) != test.equal {
+			t.Fatalf("expected hashes equal to be %t for %s and %s", test.equal, test.fen1, test.fen2)
+		}
+	}
+	// checks given in Three-check are part of the hash
+	pos := StartingPosition()
+	cp := pos.copy()
+	cp.checks[White] = 1
+	if pos.ZobristHash() == cp.ZobristHash() {
+		t.Fatal("expected a check given to change the hash")
+	}
+	other := pos.copy()
+	other.checks[Black] = 1
+	if other.ZobristHash() == cp.ZobristHash() {
+		t.Fatal("expected checks given by each color to hash differently")
+	}
+	for _, test := range tests {
+		h1 := unsafeFEN(test.fen1).ZobristHash()
+		h2 := unsafeFEN(test.fen2).ZobristHash()
+		if (h1 == h2) != test.equal {
+			t.Fatalf("expected hashes equal to be %t for %s and %s", test.equal, test.fen1, test.fen2)
+		}
+	}
+}
+
+func BenchmarkZobristHash(b *testing.B) {
+	pos := StartingPosition()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		pos.ZobristHash()
+	}
+}
+
+func TestZobristHashEnPassantAllocs(t *testing.T) {
+	pos := unsafeFEN("4k3/8/8/8/3pP3/8/8/4K3 b - e3 0 1")
+	allocs := testing.AllocsPerRun(100, func() {
+		pos.ZobristHash()
+	})
+	if allocs != 0 {
+		t.Fatalf("expected hashing with an en passant square not to allocate but got %v allocs", allocs)
+	}
+	if pos.cachedMoves() != nil {
+		t.Fatal("expected hashing not to generate the valid moves")
+	}
+}